@@ -0,0 +1,93 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	jwtIssuer             = "chirpy"
+	accessTokenExpiresIn  = time.Hour
+	refreshTokenExpiresIn = 60 * 24 * time.Hour
+)
+
+// makeJWT signs an HS256 access token whose subject is the user ID
+func makeJWT(userID uuid.UUID, secret string, expiresIn time.Duration) (string, error) {
+	now := time.Now().UTC()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Issuer:    jwtIssuer,
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)),
+		Subject:   userID.String(),
+	})
+	return token.SignedString([]byte(secret))
+}
+
+// validateJWT checks the token's signature and expiry and returns the user
+// ID from its subject
+func validateJWT(tokenString, secret string) (uuid.UUID, error) {
+	claims := jwt.RegisteredClaims{}
+	_, err := jwt.ParseWithClaims(
+		tokenString,
+		&claims,
+		func(token *jwt.Token) (interface{}, error) { return []byte(secret), nil },
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(jwtIssuer),
+	)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return uuid.Parse(claims.Subject)
+}
+
+// makeRefreshToken returns 256 random bits, hex encoded
+func makeRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// getBearerToken extracts the token from an "Authorization: Bearer <token>" header
+func getBearerToken(headers http.Header) (string, error) {
+	authHeader := headers.Get("Authorization")
+	if authHeader == "" {
+		return "", errors.New("no authorization header included")
+	}
+	token, ok := strings.CutPrefix(authHeader, "Bearer ")
+	if !ok || strings.TrimSpace(token) == "" {
+		return "", errors.New("malformed authorization header")
+	}
+	return strings.TrimSpace(token), nil
+}
+
+// getAPIKey extracts the key from an "Authorization: ApiKey <key>" header
+func getAPIKey(headers http.Header) (string, error) {
+	authHeader := headers.Get("Authorization")
+	if authHeader == "" {
+		return "", errors.New("no authorization header included")
+	}
+	key, ok := strings.CutPrefix(authHeader, "ApiKey ")
+	if !ok || strings.TrimSpace(key) == "" {
+		return "", errors.New("malformed authorization header")
+	}
+	return strings.TrimSpace(key), nil
+}
+
+// userIDFromRequest validates the request's bearer access token and returns
+// the authenticated user's ID
+func (cfg *apiConfig) userIDFromRequest(r *http.Request) (uuid.UUID, error) {
+	token, err := getBearerToken(r.Header)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return validateJWT(token, cfg.jwtSecret)
+}
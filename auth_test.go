@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func TestJWTRoundTrip(t *testing.T) {
+	userID := uuid.New()
+	token, err := makeJWT(userID, testJWTSecret, time.Hour)
+	if err != nil {
+		t.Fatalf("makeJWT: %v", err)
+	}
+	got, err := validateJWT(token, testJWTSecret)
+	if err != nil {
+		t.Fatalf("validateJWT: %v", err)
+	}
+	if got != userID {
+		t.Errorf("subject = %s, want %s", got, userID)
+	}
+
+	if _, err := validateJWT(token, "other-secret"); err == nil {
+		t.Error("token signed with another secret was accepted")
+	}
+	expired, err := makeJWT(userID, testJWTSecret, -time.Minute)
+	if err != nil {
+		t.Fatalf("makeJWT: %v", err)
+	}
+	if _, err := validateJWT(expired, testJWTSecret); err == nil {
+		t.Error("expired token was accepted")
+	}
+	none, err := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.RegisteredClaims{
+		Issuer:  jwtIssuer,
+		Subject: userID.String(),
+	}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatalf("signing unsigned token: %v", err)
+	}
+	if _, err := validateJWT(none, testJWTSecret); err == nil {
+		t.Error("unsigned token was accepted")
+	}
+}
+
+func TestGetBearerToken(t *testing.T) {
+	tests := []struct {
+		header  string
+		want    string
+		wantErr bool
+	}{
+		{"Bearer abc.def.ghi", "abc.def.ghi", false},
+		{"", "", true},
+		{"Basic abc", "", true},
+		{"Bearer   ", "", true},
+	}
+	for _, tt := range tests {
+		headers := http.Header{}
+		if tt.header != "" {
+			headers.Set("Authorization", tt.header)
+		}
+		got, err := getBearerToken(headers)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("getBearerToken(%q) = %q, %v; want %q, error %v", tt.header, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestCreateChirpRequiresToken(t *testing.T) {
+	cfg := &apiConfig{logger: testLogger, jwtSecret: testJWTSecret}
+	body := `{"body": "hello"}`
+
+	rec := httptest.NewRecorder()
+	cfg.createChirpHandler(rec, httptest.NewRequest(http.MethodPost, "/api/chirps", strings.NewReader(body)))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("no token: status = %d, want 401", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/chirps", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer not-a-jwt")
+	rec = httptest.NewRecorder()
+	cfg.createChirpHandler(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("bad token: status = %d, want 401", rec.Code)
+	}
+}
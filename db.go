@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	dbConnectBackoff = 500 * time.Millisecond
+	dbConnectTimeout = 30 * time.Second
+)
+
+// isUniqueViolation reports whether err is Postgres unique_violation (23505)
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// foreignKeyViolation reports whether err is Postgres foreign_key_violation
+// (23503) and, if so, the name of the violated constraint
+func foreignKeyViolation(err error) (constraint string, ok bool) {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+		return pqErr.Constraint, true
+	}
+	return "", false
+}
+
+// waitForDB pings db with exponential backoff until it answers or
+// dbConnectTimeout has passed, so the server can start before Postgres is up
+func waitForDB(ctx context.Context, db *sql.DB) error {
+	ctx, cancel := context.WithTimeout(ctx, dbConnectTimeout)
+	defer cancel()
+
+	backoff := dbConnectBackoff
+	for attempt := 1; ; attempt++ {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+		slog.Warn("database not ready", "attempt", attempt, "retry_in", backoff, "err", err)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
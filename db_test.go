@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestIsUniqueViolation(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"unique violation", &pq.Error{Code: "23505"}, true},
+		{"wrapped unique violation", fmt.Errorf("creating user: %w", &pq.Error{Code: "23505"}), true},
+		{"other postgres error", &pq.Error{Code: "23503"}, false},
+		{"plain error", errors.New("23505"), false},
+		{"nil", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isUniqueViolation(tt.err); got != tt.want {
+				t.Errorf("isUniqueViolation(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestForeignKeyViolation(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		wantConstraint string
+		wantOK         bool
+	}{
+		{"foreign key violation", &pq.Error{Code: "23503", Constraint: "chirp_likes_chirp_id_fkey"}, "chirp_likes_chirp_id_fkey", true},
+		{"wrapped foreign key violation", fmt.Errorf("liking chirp: %w", &pq.Error{Code: "23503", Constraint: "chirp_likes_user_id_fkey"}), "chirp_likes_user_id_fkey", true},
+		{"unique violation", &pq.Error{Code: "23505"}, "", false},
+		{"plain error", errors.New("23503"), "", false},
+		{"nil", nil, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			constraint, ok := foreignKeyViolation(tt.err)
+			if constraint != tt.wantConstraint || ok != tt.wantOK {
+				t.Errorf("foreignKeyViolation(%v) = %q, %v; want %q, %v", tt.err, constraint, ok, tt.wantConstraint, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"compress/gzip"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Bodies smaller than this are sent as-is; gzip overhead isn't worth it
+const gzipMinSize = 1024
+
+// gzipResponseWriter holds back the start of the body until it knows
+// whether the response is big enough and of a type worth compressing
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	statusCode int
+	buf        []byte
+	started    bool
+	gz         *gzip.Writer
+}
+
+func (g *gzipResponseWriter) WriteHeader(code int) {
+	if g.statusCode == 0 {
+		g.statusCode = code
+	}
+}
+
+func (g *gzipResponseWriter) Write(b []byte) (int, error) {
+	if g.statusCode == 0 {
+		g.statusCode = http.StatusOK
+	}
+	if g.gz != nil {
+		return g.gz.Write(b)
+	}
+	if g.started {
+		return g.ResponseWriter.Write(b)
+	}
+
+	g.buf = append(g.buf, b...)
+	if len(g.buf) >= gzipMinSize {
+		if err := g.start(g.shouldCompress()); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush settles whether to compress based on what has been written so far,
+// sends the headers and pushes any buffered bytes through to the client.
+// http.ResponseController finds this before falling back to Unwrap, so a
+// flush never bypasses the held-back body or the gzip stream.
+func (g *gzipResponseWriter) Flush() {
+	if !g.started {
+		if g.statusCode == 0 {
+			g.statusCode = http.StatusOK
+		}
+		if err := g.start(g.shouldCompress()); err != nil {
+			return
+		}
+	}
+	if g.gz != nil {
+		if err := g.gz.Flush(); err != nil {
+			return
+		}
+	}
+	http.NewResponseController(g.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer for
+// everything but Flush, e.g. write deadlines
+func (g *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return g.ResponseWriter
+}
+
+func (g *gzipResponseWriter) shouldCompress() bool {
+	// Partial content must match the byte ranges the client asked for, and
+	// these statuses carry no body to compress
+	switch g.statusCode {
+	case http.StatusPartialContent, http.StatusNoContent, http.StatusNotModified:
+		return false
+	}
+	if g.Header().Get("Content-Encoding") != "" {
+		return false
+	}
+	contentType := g.Header().Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(g.buf)
+	}
+	return isCompressibleContentType(contentType)
+}
+
+// start sends the headers and whatever body has been held back
+func (g *gzipResponseWriter) start(compress bool) error {
+	g.started = true
+	if compress {
+		g.Header().Set("Content-Encoding", "gzip")
+		// Any length set by the handler describes the uncompressed body
+		g.Header().Del("Content-Length")
+		g.gz = gzip.NewWriter(g.ResponseWriter)
+	}
+	g.ResponseWriter.WriteHeader(g.statusCode)
+
+	buf := g.buf
+	g.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if compress {
+		_, err := g.gz.Write(buf)
+		return err
+	}
+	_, err := g.ResponseWriter.Write(buf)
+	return err
+}
+
+// close sends a small body uncompressed, or finishes the gzip stream
+func (g *gzipResponseWriter) close() error {
+	if !g.started {
+		if g.statusCode == 0 {
+			g.statusCode = http.StatusOK
+		}
+		return g.start(false)
+	}
+	if g.gz != nil {
+		return g.gz.Close()
+	}
+	return nil
+}
+
+// isCompressibleContentType allows text-like types; images, archives and
+// other binary formats are usually compressed already
+func isCompressibleContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if strings.HasPrefix(mediaType, "text/") {
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/javascript", "application/xml", "image/svg+xml":
+		return true
+	}
+	return false
+}
+
+// acceptsGzip reports whether the client listed gzip without q=0
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if weight, err := strconv.ParseFloat(q, 64); err == nil && weight == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// middlewareGzip compresses sizeable text responses for clients that accept it
+func middlewareGzip(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		// HEAD responses have no body to compress, so pass them through untouched
+		if r.Method == http.MethodHead || !acceptsGzip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		gw := &gzipResponseWriter{ResponseWriter: w}
+		defer gw.close()
+		next.ServeHTTP(gw, r)
+	})
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// serveGzip runs handler behind middlewareGzip for a request that accepts gzip
+func serveGzip(t *testing.T, method, acceptEncoding string, handler http.HandlerFunc) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, "/", nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	rec := httptest.NewRecorder()
+	middlewareGzip(handler).ServeHTTP(rec, req)
+	return rec
+}
+
+// gunzip decodes a compressed response body
+func gunzip(t *testing.T, body []byte) string {
+	t.Helper()
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("gzip.NewReader: %v", err)
+	}
+	plain, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("reading gzip body: %v", err)
+	}
+	return string(plain)
+}
+
+func TestMiddlewareGzipThreshold(t *testing.T) {
+	tests := []struct {
+		name         string
+		size         int
+		wantCompress bool
+	}{
+		{"empty", 0, false},
+		{"just under", gzipMinSize - 1, false},
+		{"at the threshold", gzipMinSize, true},
+		{"well over", 10 * gzipMinSize, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := strings.Repeat("a", tt.size)
+			rec := serveGzip(t, http.MethodGet, "gzip", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				io.WriteString(w, body)
+			})
+
+			if rec.Code != http.StatusOK {
+				t.Errorf("status = %d, want 200", rec.Code)
+			}
+			if got := rec.Header().Get("Vary"); got != "Accept-Encoding" {
+				t.Errorf("Vary = %q, want Accept-Encoding", got)
+			}
+			compressed := rec.Header().Get("Content-Encoding") == "gzip"
+			if compressed != tt.wantCompress {
+				t.Fatalf("compressed = %v, want %v", compressed, tt.wantCompress)
+			}
+			got := rec.Body.String()
+			if compressed {
+				got = gunzip(t, rec.Body.Bytes())
+			}
+			if got != body {
+				t.Errorf("body is %d bytes, want %d", len(got), len(body))
+			}
+		})
+	}
+}
+
+func TestMiddlewareGzipPassthrough(t *testing.T) {
+	large := strings.Repeat("a", 2*gzipMinSize)
+
+	tests := []struct {
+		name           string
+		method         string
+		acceptEncoding string
+		contentType    string
+		status         int
+		body           string
+	}{
+		{"client did not ask", http.MethodGet, "", "text/plain", http.StatusOK, large},
+		{"gzip refused with q=0", http.MethodGet, "br, gzip;q=0", "text/plain", http.StatusOK, large},
+		{"already compressed type", http.MethodGet, "gzip", "image/png", http.StatusOK, large},
+		{"archive type", http.MethodGet, "gzip", "application/zip", http.StatusOK, large},
+		{"partial content", http.MethodGet, "gzip", "text/plain", http.StatusPartialContent, large},
+		{"no content", http.MethodGet, "gzip", "", http.StatusNoContent, ""},
+		{"head", http.MethodHead, "gzip", "text/plain", http.StatusOK, large},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveGzip(t, tt.method, tt.acceptEncoding, func(w http.ResponseWriter, r *http.Request) {
+				if tt.contentType != "" {
+					w.Header().Set("Content-Type", tt.contentType)
+				}
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.body)
+			})
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if got := rec.Header().Get("Content-Encoding"); got != "" {
+				t.Errorf("Content-Encoding = %q, want none", got)
+			}
+			if rec.Body.String() != tt.body {
+				t.Errorf("body is %d bytes, want the %d bytes written", rec.Body.Len(), len(tt.body))
+			}
+		})
+	}
+}
+
+func TestMiddlewareGzipDropsContentLength(t *testing.T) {
+	body := strings.Repeat("a", 2*gzipMinSize)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		io.WriteString(w, body)
+	}
+
+	rec := serveGzip(t, http.MethodGet, "gzip", handler)
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatal("response was not compressed")
+	}
+	if got := rec.Header().Get("Content-Length"); got != "" {
+		t.Errorf("Content-Length = %q, want it removed", got)
+	}
+
+	// Uncompressed responses keep the handler's length
+	rec = serveGzip(t, http.MethodGet, "", handler)
+	if got := rec.Header().Get("Content-Length"); got != strconv.Itoa(len(body)) {
+		t.Errorf("uncompressed Content-Length = %q, want %d", got, len(body))
+	}
+}
+
+func TestMiddlewareGzipFlush(t *testing.T) {
+	first, rest := "hello, ", strings.Repeat("a", 2*gzipMinSize)
+	// A real server, since a recorder doesn't freeze headers once they're sent
+	srv := httptest.NewServer(middlewareGzip(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, first)
+		// Flushing before the threshold must still commit to gzip
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("Flush: %v", err)
+		}
+		io.WriteString(w, rest)
+	})))
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	// Asking explicitly stops the transport from decompressing for us
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading body: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", got)
+	}
+	if got := gunzip(t, body); got != first+rest {
+		t.Errorf("body is %d bytes after gunzip, want %d", len(got), len(first+rest))
+	}
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"deflate, GZIP", true},
+		{"gzip;q=0.5", true},
+		{"gzip;q=0", false},
+		{"gzip; q=0", false},
+		{"br", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Encoding", tt.header)
+		if got := acceptsGzip(req); got != tt.want {
+			t.Errorf("acceptsGzip(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
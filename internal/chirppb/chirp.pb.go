@@ -0,0 +1,393 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: chirp.proto
+
+package chirppb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Chirp mirrors the JSON chirp representation
+type Chirp struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Id        string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	CreatedAt *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	Body      string                 `protobuf:"bytes,4,opt,name=body,proto3" json:"body,omitempty"`
+	UserId    string                 `protobuf:"bytes,5,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	// Unset for chirps that never expire
+	ExpiresAt *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	IsFlagged bool                   `protobuf:"varint,7,opt,name=is_flagged,json=isFlagged,proto3" json:"is_flagged,omitempty"`
+	// Only set when the listing was requested with expand=author
+	Author *User `protobuf:"bytes,8,opt,name=author,proto3" json:"author,omitempty"`
+	// Set on quote-reposts; quoted_chirp is only hydrated one level deep
+	QuotedChirpId string `protobuf:"bytes,9,opt,name=quoted_chirp_id,json=quotedChirpId,proto3" json:"quoted_chirp_id,omitempty"`
+	QuotedChirp   *Chirp `protobuf:"bytes,10,opt,name=quoted_chirp,json=quotedChirp,proto3" json:"quoted_chirp,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Chirp) Reset() {
+	*x = Chirp{}
+	mi := &file_chirp_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Chirp) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Chirp) ProtoMessage() {}
+
+func (x *Chirp) ProtoReflect() protoreflect.Message {
+	mi := &file_chirp_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Chirp.ProtoReflect.Descriptor instead.
+func (*Chirp) Descriptor() ([]byte, []int) {
+	return file_chirp_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Chirp) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Chirp) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Chirp) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+func (x *Chirp) GetBody() string {
+	if x != nil {
+		return x.Body
+	}
+	return ""
+}
+
+func (x *Chirp) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *Chirp) GetExpiresAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return nil
+}
+
+func (x *Chirp) GetIsFlagged() bool {
+	if x != nil {
+		return x.IsFlagged
+	}
+	return false
+}
+
+func (x *Chirp) GetAuthor() *User {
+	if x != nil {
+		return x.Author
+	}
+	return nil
+}
+
+func (x *Chirp) GetQuotedChirpId() string {
+	if x != nil {
+		return x.QuotedChirpId
+	}
+	return ""
+}
+
+func (x *Chirp) GetQuotedChirp() *Chirp {
+	if x != nil {
+		return x.QuotedChirp
+	}
+	return nil
+}
+
+// User mirrors the JSON user representation embedded in chirps
+type User struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt     *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	Email         string                 `protobuf:"bytes,4,opt,name=email,proto3" json:"email,omitempty"`
+	IsChirpyRed   bool                   `protobuf:"varint,5,opt,name=is_chirpy_red,json=isChirpyRed,proto3" json:"is_chirpy_red,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *User) Reset() {
+	*x = User{}
+	mi := &file_chirp_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *User) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*User) ProtoMessage() {}
+
+func (x *User) ProtoReflect() protoreflect.Message {
+	mi := &file_chirp_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use User.ProtoReflect.Descriptor instead.
+func (*User) Descriptor() ([]byte, []int) {
+	return file_chirp_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *User) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *User) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *User) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+func (x *User) GetEmail() string {
+	if x != nil {
+		return x.Email
+	}
+	return ""
+}
+
+func (x *User) GetIsChirpyRed() bool {
+	if x != nil {
+		return x.IsChirpyRed
+	}
+	return false
+}
+
+type ChirpList struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Chirps []*Chirp               `protobuf:"bytes,1,rep,name=chirps,proto3" json:"chirps,omitempty"`
+	// Only set for page-number listings (page/per_page)
+	Page          int32 `protobuf:"varint,2,opt,name=page,proto3" json:"page,omitempty"`
+	PerPage       int32 `protobuf:"varint,3,opt,name=per_page,json=perPage,proto3" json:"per_page,omitempty"`
+	TotalPages    int32 `protobuf:"varint,4,opt,name=total_pages,json=totalPages,proto3" json:"total_pages,omitempty"`
+	Total         int64 `protobuf:"varint,5,opt,name=total,proto3" json:"total,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChirpList) Reset() {
+	*x = ChirpList{}
+	mi := &file_chirp_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChirpList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChirpList) ProtoMessage() {}
+
+func (x *ChirpList) ProtoReflect() protoreflect.Message {
+	mi := &file_chirp_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChirpList.ProtoReflect.Descriptor instead.
+func (*ChirpList) Descriptor() ([]byte, []int) {
+	return file_chirp_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ChirpList) GetChirps() []*Chirp {
+	if x != nil {
+		return x.Chirps
+	}
+	return nil
+}
+
+func (x *ChirpList) GetPage() int32 {
+	if x != nil {
+		return x.Page
+	}
+	return 0
+}
+
+func (x *ChirpList) GetPerPage() int32 {
+	if x != nil {
+		return x.PerPage
+	}
+	return 0
+}
+
+func (x *ChirpList) GetTotalPages() int32 {
+	if x != nil {
+		return x.TotalPages
+	}
+	return 0
+}
+
+func (x *ChirpList) GetTotal() int64 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+var File_chirp_proto protoreflect.FileDescriptor
+
+const file_chirp_proto_rawDesc = "" +
+	"\n" +
+	"\vchirp.proto\x12\x06chirpy\x1a\x1fgoogle/protobuf/timestamp.proto\"\x94\x03\n" +
+	"\x05Chirp\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x129\n" +
+	"\n" +
+	"created_at\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x12\x12\n" +
+	"\x04body\x18\x04 \x01(\tR\x04body\x12\x17\n" +
+	"\auser_id\x18\x05 \x01(\tR\x06userId\x129\n" +
+	"\n" +
+	"expires_at\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\texpiresAt\x12\x1d\n" +
+	"\n" +
+	"is_flagged\x18\a \x01(\bR\tisFlagged\x12$\n" +
+	"\x06author\x18\b \x01(\v2\f.chirpy.UserR\x06author\x12&\n" +
+	"\x0fquoted_chirp_id\x18\t \x01(\tR\rquotedChirpId\x120\n" +
+	"\fquoted_chirp\x18\n" +
+	" \x01(\v2\r.chirpy.ChirpR\vquotedChirp\"\xc6\x01\n" +
+	"\x04User\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x129\n" +
+	"\n" +
+	"created_at\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x12\x14\n" +
+	"\x05email\x18\x04 \x01(\tR\x05email\x12\"\n" +
+	"\ris_chirpy_red\x18\x05 \x01(\bR\visChirpyRed\"\x98\x01\n" +
+	"\tChirpList\x12%\n" +
+	"\x06chirps\x18\x01 \x03(\v2\r.chirpy.ChirpR\x06chirps\x12\x12\n" +
+	"\x04page\x18\x02 \x01(\x05R\x04page\x12\x19\n" +
+	"\bper_page\x18\x03 \x01(\x05R\aperPage\x12\x1f\n" +
+	"\vtotal_pages\x18\x04 \x01(\x05R\n" +
+	"totalPages\x12\x14\n" +
+	"\x05total\x18\x05 \x01(\x03R\x05totalB5Z3github.com/troydot1x/chirpy_server/internal/chirppbb\x06proto3"
+
+var (
+	file_chirp_proto_rawDescOnce sync.Once
+	file_chirp_proto_rawDescData []byte
+)
+
+func file_chirp_proto_rawDescGZIP() []byte {
+	file_chirp_proto_rawDescOnce.Do(func() {
+		file_chirp_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_chirp_proto_rawDesc), len(file_chirp_proto_rawDesc)))
+	})
+	return file_chirp_proto_rawDescData
+}
+
+var file_chirp_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_chirp_proto_goTypes = []any{
+	(*Chirp)(nil),                 // 0: chirpy.Chirp
+	(*User)(nil),                  // 1: chirpy.User
+	(*ChirpList)(nil),             // 2: chirpy.ChirpList
+	(*timestamppb.Timestamp)(nil), // 3: google.protobuf.Timestamp
+}
+var file_chirp_proto_depIdxs = []int32{
+	3, // 0: chirpy.Chirp.created_at:type_name -> google.protobuf.Timestamp
+	3, // 1: chirpy.Chirp.updated_at:type_name -> google.protobuf.Timestamp
+	3, // 2: chirpy.Chirp.expires_at:type_name -> google.protobuf.Timestamp
+	1, // 3: chirpy.Chirp.author:type_name -> chirpy.User
+	0, // 4: chirpy.Chirp.quoted_chirp:type_name -> chirpy.Chirp
+	3, // 5: chirpy.User.created_at:type_name -> google.protobuf.Timestamp
+	3, // 6: chirpy.User.updated_at:type_name -> google.protobuf.Timestamp
+	0, // 7: chirpy.ChirpList.chirps:type_name -> chirpy.Chirp
+	8, // [8:8] is the sub-list for method output_type
+	8, // [8:8] is the sub-list for method input_type
+	8, // [8:8] is the sub-list for extension type_name
+	8, // [8:8] is the sub-list for extension extendee
+	0, // [0:8] is the sub-list for field type_name
+}
+
+func init() { file_chirp_proto_init() }
+func file_chirp_proto_init() {
+	if File_chirp_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_chirp_proto_rawDesc), len(file_chirp_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   3,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_chirp_proto_goTypes,
+		DependencyIndexes: file_chirp_proto_depIdxs,
+		MessageInfos:      file_chirp_proto_msgTypes,
+	}.Build()
+	File_chirp_proto = out.File
+	file_chirp_proto_goTypes = nil
+	file_chirp_proto_depIdxs = nil
+}
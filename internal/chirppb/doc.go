@@ -0,0 +1,5 @@
+// Package chirppb holds the protobuf encoding of chirps served to clients
+// that send Accept: application/x-protobuf.
+package chirppb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative chirp.proto
@@ -7,23 +7,59 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const countChirps = `-- name: CountChirps :one
+SELECT COUNT(*) FROM chirps
+WHERE ($1::uuid IS NULL OR user_id = $1)
+AND ($2::text IS NULL OR body ILIKE '%' || $2 || '%')
+AND (expires_at IS NULL OR expires_at > NOW() AT TIME ZONE 'UTC')
+`
+
+type CountChirpsParams struct {
+	UserID uuid.NullUUID
+	Search sql.NullString
+}
+
+func (q *Queries) CountChirps(ctx context.Context, arg CountChirpsParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countChirps, arg.UserID, arg.Search)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countChirpsByUser = `-- name: CountChirpsByUser :one
+SELECT COUNT(*) FROM chirps
+WHERE user_id = $1
+`
+
+func (q *Queries) CountChirpsByUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countChirpsByUser, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
-VALUES ($1, $2, $3, $4, $5)
-RETURNING id, created_at, updated_at, body, user_id
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, expires_at, is_flagged, quoted_chirp_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING id, created_at, updated_at, body, user_id, expires_at, is_flagged, quoted_chirp_id
 `
 
 type CreateChirpParams struct {
-	ID        uuid.UUID
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	Body      string
-	UserID    uuid.UUID
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	Body          string
+	UserID        uuid.UUID
+	ExpiresAt     sql.NullTime
+	IsFlagged     bool
+	QuotedChirpID uuid.NullUUID
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
@@ -33,6 +69,9 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		arg.UpdatedAt,
 		arg.Body,
 		arg.UserID,
+		arg.ExpiresAt,
+		arg.IsFlagged,
+		arg.QuotedChirpID,
 	)
 	var i Chirp
 	err := row.Scan(
@@ -41,13 +80,76 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.ExpiresAt,
+		&i.IsFlagged,
+		&i.QuotedChirpID,
 	)
 	return i, err
 }
 
+const deleteChirp = `-- name: DeleteChirp :exec
+DELETE FROM chirps
+WHERE id = $1
+`
+
+func (q *Queries) DeleteChirp(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteChirp, id)
+	return err
+}
+
+const deleteExpiredChirps = `-- name: DeleteExpiredChirps :execrows
+DELETE FROM chirps
+WHERE expires_at <= NOW() AT TIME ZONE 'UTC'
+`
+
+func (q *Queries) DeleteExpiredChirps(ctx context.Context) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteExpiredChirps)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getChirpBodiesByUser = `-- name: GetChirpBodiesByUser :many
+SELECT body FROM chirps
+WHERE user_id = $1
+AND (expires_at IS NULL OR expires_at > NOW() AT TIME ZONE 'UTC')
+ORDER BY created_at DESC
+LIMIT $2
+`
+
+type GetChirpBodiesByUserParams struct {
+	UserID uuid.UUID
+	Limit  int32
+}
+
+func (q *Queries) GetChirpBodiesByUser(ctx context.Context, arg GetChirpBodiesByUserParams) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpBodiesByUser, arg.UserID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var body string
+		if err := rows.Scan(&body); err != nil {
+			return nil, err
+		}
+		items = append(items, body)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getChirpByID = `-- name: GetChirpByID :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps 
+SELECT id, created_at, updated_at, body, user_id, expires_at, is_flagged, quoted_chirp_id FROM chirps 
 WHERE id = $1
+AND (expires_at IS NULL OR expires_at > NOW() AT TIME ZONE 'UTC')
 `
 
 func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error) {
@@ -59,17 +161,460 @@ func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error)
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.ExpiresAt,
+		&i.IsFlagged,
+		&i.QuotedChirpID,
 	)
 	return i, err
 }
 
-const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps 
+const getChirpIDs = `-- name: GetChirpIDs :many
+SELECT id, updated_at FROM chirps
+WHERE ($1::uuid IS NULL OR user_id = $1)
+AND (expires_at IS NULL OR expires_at > NOW() AT TIME ZONE 'UTC')
+ORDER BY
+    CASE WHEN $2::bool THEN created_at END DESC,
+    CASE WHEN $2::bool THEN id END DESC,
+    created_at ASC,
+    id ASC
+`
+
+type GetChirpIDsParams struct {
+	UserID   uuid.NullUUID
+	SortDesc bool
+}
+
+type GetChirpIDsRow struct {
+	ID        uuid.UUID
+	UpdatedAt time.Time
+}
+
+func (q *Queries) GetChirpIDs(ctx context.Context, arg GetChirpIDsParams) ([]GetChirpIDsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpIDs, arg.UserID, arg.SortDesc)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetChirpIDsRow
+	for rows.Next() {
+		var i GetChirpIDsRow
+		if err := rows.Scan(&i.ID, &i.UpdatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsAfter = `-- name: GetChirpsAfter :many
+SELECT id, created_at, updated_at, body, user_id, expires_at, is_flagged, quoted_chirp_id FROM chirps
+WHERE (created_at, id) > ($1::timestamp, $2::uuid)
+AND ($3::uuid IS NULL OR user_id = $3)
+AND (expires_at IS NULL OR expires_at > NOW() AT TIME ZONE 'UTC')
+ORDER BY created_at ASC, id ASC
+LIMIT $4
+`
+
+type GetChirpsAfterParams struct {
+	CreatedAt  time.Time
+	ID         uuid.UUID
+	UserID     uuid.NullUUID
+	MaxResults int32
+}
+
+func (q *Queries) GetChirpsAfter(ctx context.Context, arg GetChirpsAfterParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsAfter,
+		arg.CreatedAt,
+		arg.ID,
+		arg.UserID,
+		arg.MaxResults,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.ExpiresAt,
+			&i.IsFlagged,
+			&i.QuotedChirpID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsBefore = `-- name: GetChirpsBefore :many
+SELECT id, created_at, updated_at, body, user_id, expires_at, is_flagged, quoted_chirp_id FROM chirps
+WHERE (created_at, id) < ($1::timestamp, $2::uuid)
+AND ($3::uuid IS NULL OR user_id = $3)
+AND (expires_at IS NULL OR expires_at > NOW() AT TIME ZONE 'UTC')
+ORDER BY created_at DESC, id DESC
+LIMIT $4
+`
+
+type GetChirpsBeforeParams struct {
+	CreatedAt  time.Time
+	ID         uuid.UUID
+	UserID     uuid.NullUUID
+	MaxResults int32
+}
+
+func (q *Queries) GetChirpsBefore(ctx context.Context, arg GetChirpsBeforeParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsBefore,
+		arg.CreatedAt,
+		arg.ID,
+		arg.UserID,
+		arg.MaxResults,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.ExpiresAt,
+			&i.IsFlagged,
+			&i.QuotedChirpID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsByIDs = `-- name: GetChirpsByIDs :many
+SELECT id, created_at, updated_at, body, user_id, expires_at, is_flagged, quoted_chirp_id FROM chirps
+WHERE id = ANY($1::uuid[])
+AND (expires_at IS NULL OR expires_at > NOW() AT TIME ZONE 'UTC')
+`
+
+func (q *Queries) GetChirpsByIDs(ctx context.Context, ids []uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByIDs, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.ExpiresAt,
+			&i.IsFlagged,
+			&i.QuotedChirpID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsByUserBetween = `-- name: GetChirpsByUserBetween :many
+SELECT id, created_at, updated_at, body, user_id, expires_at, is_flagged, quoted_chirp_id FROM chirps
+WHERE user_id = $1
+AND created_at >= $2 AND created_at < $3
+AND (expires_at IS NULL OR expires_at > NOW() AT TIME ZONE 'UTC')
+ORDER BY created_at ASC
+`
+
+type GetChirpsByUserBetweenParams struct {
+	UserID    uuid.UUID
+	StartTime time.Time
+	EndTime   time.Time
+}
+
+func (q *Queries) GetChirpsByUserBetween(ctx context.Context, arg GetChirpsByUserBetweenParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByUserBetween, arg.UserID, arg.StartTime, arg.EndTime)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.ExpiresAt,
+			&i.IsFlagged,
+			&i.QuotedChirpID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsPaginated = `-- name: GetChirpsPaginated :many
+SELECT id, created_at, updated_at, body, user_id, expires_at, is_flagged, quoted_chirp_id FROM chirps
+WHERE ($1::uuid IS NULL OR user_id = $1)
+AND ($2::text IS NULL OR body ILIKE '%' || $2 || '%')
+AND (expires_at IS NULL OR expires_at > NOW() AT TIME ZONE 'UTC')
+ORDER BY
+    CASE WHEN $3::bool THEN created_at END DESC,
+    CASE WHEN $3::bool THEN id END DESC,
+    created_at ASC,
+    id ASC
+LIMIT $5 OFFSET $4
+`
+
+type GetChirpsPaginatedParams struct {
+	UserID     uuid.NullUUID
+	Search     sql.NullString
+	SortDesc   bool
+	Skip       int32
+	MaxResults int32
+}
+
+func (q *Queries) GetChirpsPaginated(ctx context.Context, arg GetChirpsPaginatedParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsPaginated,
+		arg.UserID,
+		arg.Search,
+		arg.SortDesc,
+		arg.Skip,
+		arg.MaxResults,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.ExpiresAt,
+			&i.IsFlagged,
+			&i.QuotedChirpID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsSince = `-- name: GetChirpsSince :many
+SELECT id, created_at, updated_at, body, user_id, expires_at, is_flagged, quoted_chirp_id FROM chirps
+WHERE created_at >= $1
+AND (expires_at IS NULL OR expires_at > NOW() AT TIME ZONE 'UTC')
+ORDER BY created_at DESC
+`
+
+func (q *Queries) GetChirpsSince(ctx context.Context, createdAt time.Time) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsSince, createdAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.ExpiresAt,
+			&i.IsFlagged,
+			&i.QuotedChirpID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsWithAuthor = `-- name: GetChirpsWithAuthor :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.expires_at, chirps.is_flagged, chirps.quoted_chirp_id,
+    users.email AS author_email,
+    users.created_at AS author_created_at,
+    users.updated_at AS author_updated_at,
+    users.is_chirpy_red AS author_is_chirpy_red
+FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE ($1::uuid IS NULL OR chirps.user_id = $1)
+AND ($2::text IS NULL OR chirps.body ILIKE '%' || $2 || '%')
+AND (chirps.expires_at IS NULL OR chirps.expires_at > NOW() AT TIME ZONE 'UTC')
+ORDER BY
+    CASE WHEN $3::bool THEN chirps.created_at END DESC,
+    CASE WHEN $3::bool THEN chirps.id END DESC,
+    chirps.created_at ASC,
+    chirps.id ASC
+LIMIT $5 OFFSET $4
+`
+
+type GetChirpsWithAuthorParams struct {
+	UserID     uuid.NullUUID
+	Search     sql.NullString
+	SortDesc   bool
+	Skip       int32
+	MaxResults int32
+}
+
+type GetChirpsWithAuthorRow struct {
+	Chirp             Chirp
+	AuthorEmail       string
+	AuthorCreatedAt   time.Time
+	AuthorUpdatedAt   time.Time
+	AuthorIsChirpyRed bool
+}
+
+func (q *Queries) GetChirpsWithAuthor(ctx context.Context, arg GetChirpsWithAuthorParams) ([]GetChirpsWithAuthorRow, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsWithAuthor,
+		arg.UserID,
+		arg.Search,
+		arg.SortDesc,
+		arg.Skip,
+		arg.MaxResults,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetChirpsWithAuthorRow
+	for rows.Next() {
+		var i GetChirpsWithAuthorRow
+		if err := rows.Scan(
+			&i.Chirp.ID,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.Body,
+			&i.Chirp.UserID,
+			&i.Chirp.ExpiresAt,
+			&i.Chirp.IsFlagged,
+			&i.Chirp.QuotedChirpID,
+			&i.AuthorEmail,
+			&i.AuthorCreatedAt,
+			&i.AuthorUpdatedAt,
+			&i.AuthorIsChirpyRed,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getExistingChirpIDs = `-- name: GetExistingChirpIDs :many
+SELECT id FROM chirps
+WHERE id = ANY($1::uuid[])
+AND (expires_at IS NULL OR expires_at > NOW() AT TIME ZONE 'UTC')
+`
+
+func (q *Queries) GetExistingChirpIDs(ctx context.Context, ids []uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, getExistingChirpIDs, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getQuotesOfChirp = `-- name: GetQuotesOfChirp :many
+SELECT id, created_at, updated_at, body, user_id, expires_at, is_flagged, quoted_chirp_id FROM chirps
+WHERE quoted_chirp_id = $1
+AND (expires_at IS NULL OR expires_at > NOW() AT TIME ZONE 'UTC')
 ORDER BY created_at ASC
 `
 
-func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirps)
+func (q *Queries) GetQuotesOfChirp(ctx context.Context, quotedChirpID uuid.NullUUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getQuotesOfChirp, quotedChirpID)
 	if err != nil {
 		return nil, err
 	}
@@ -83,6 +628,9 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.ExpiresAt,
+			&i.IsFlagged,
+			&i.QuotedChirpID,
 		); err != nil {
 			return nil, err
 		}
@@ -96,3 +644,68 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 	}
 	return items, nil
 }
+
+const seedChirp = `-- name: SeedChirp :execrows
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (id) DO NOTHING
+`
+
+type SeedChirpParams struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Body      string
+	UserID    uuid.UUID
+	ExpiresAt sql.NullTime
+}
+
+func (q *Queries) SeedChirp(ctx context.Context, arg SeedChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, seedChirp,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.Body,
+		arg.UserID,
+		arg.ExpiresAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateChirp = `-- name: UpdateChirp :one
+UPDATE chirps
+SET body = $2, is_flagged = $3, updated_at = $4
+WHERE id = $1
+RETURNING id, created_at, updated_at, body, user_id, expires_at, is_flagged, quoted_chirp_id
+`
+
+type UpdateChirpParams struct {
+	ID        uuid.UUID
+	Body      string
+	IsFlagged bool
+	UpdatedAt time.Time
+}
+
+func (q *Queries) UpdateChirp(ctx context.Context, arg UpdateChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirp,
+		arg.ID,
+		arg.Body,
+		arg.IsFlagged,
+		arg.UpdatedAt,
+	)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.ExpiresAt,
+		&i.IsFlagged,
+		&i.QuotedChirpID,
+	)
+	return i, err
+}
@@ -1,28 +1,133 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/subtle"
 	"database/sql"
+	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"html/template"
 	"log"
+	"log/slog"
+	"math"
+	"math/big"
 	"net/http"
+	"net/mail"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/troydot1x/chirpy_server/internal/database"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/time/rate"
+)
+
+//go:embed templates/chirps.html
+var chirpsTemplateSource string
+
+var chirpsTemplate = template.Must(template.New("chirps").Parse(chirpsTemplateSource))
+
+// A valid bcrypt hash compared against when the email is unknown, so login
+// takes about as long whether or not the account exists
+const dummyPasswordHash = "$2a$10$znU/Op6wOklplbMbManXK.XzXLYImnojwivgt6uqN4lbiy1BCPQYC"
+
+const requestIDHeader = "X-Request-Id"
+
+const (
+	maxChirpLength = 140
+	profanityMask  = "****"
+
+	defaultRecentMinutes = 5
+	maxRecentMinutes     = 60
+
+	maxChirpLifetime = 7 * 24 * time.Hour
+
+	defaultPerPage = 20
+	maxPerPage     = 100
+
+	defaultChirpsLimit = 50
+	maxChirpsLimit     = 100
+
+	healthCheckTimeout = 2 * time.Second
+
+	defaultMaxBodyBytes = 1 << 20
+
+	// Kept well under Postgres' default max_connections of 100
+	defaultDBMaxOpen         = 25
+	defaultDBMaxIdle         = 25
+	defaultDBConnMaxLifetime = 5 * time.Minute
+
+	defaultContextSize = 2
+	maxContextSize     = 20
+
+	maxExistsIDs = 100
+
+	defaultWordStatsLimit = 10
+	maxWordStatsLimit     = 50
+	maxWordStatsChirps    = 500
 )
 
 type apiConfig struct {
 	fileserverHits atomic.Int32
 	db             *database.Queries
+	dbConn         *sql.DB
+	logger         *slog.Logger
 	platform       string
+	jwtSecret      string
+	polkaKey       string
+	// Basic auth credentials for /admin/ routes
+	adminUser   string
+	adminPass   string
+	urlIDFormat string
+	// Stands in for time.Now when set, so tests can pin the clock
+	clock func() time.Time
+	// Lowercased domains; a "*." prefix also matches subdomains
+	bannedEmailDomains map[string]struct{}
+	// Lifetime cap on chirps per user; zero means unlimited
+	maxChirpsPerUser int
+	// UUID version for new IDs: 4 (random) or 7 (time-ordered)
+	uuidVersion int
+	// Minimum account age before a user may post; zero disables the check
+	minAccountAgeToPost time.Duration
+	// Guards profanity, which may be swapped by the list refresher
+	profanityMu   sync.RWMutex
+	profanity     profanityList
+	profanityMode profanityMode
+	// Attempts made for writes that hit transient DB errors
+	dbMaxAttempts int
+	// When set, mutating requests outside /admin/ are refused
+	readOnly atomic.Bool
+	// Requests served at once before shedding load; zero means unlimited
+	maxConcurrentRequests int
+	// Requests currently being handled, reported if shutdown times out
+	inFlight atomic.Int64
+	// Requests per route pattern, e.g. "POST /api/chirps"; the mutex guards
+	// adding routes, the counters themselves are atomic
+	endpointHitsMu sync.RWMutex
+	endpointHits   map[string]*atomic.Int64
+	// Prometheus request collectors; nil when not registered
+	metrics *httpMetrics
+	// Largest request body handlers will read
+	maxBodyBytes int64
+	// Per-IP limiter for abuse-prone endpoints; nil disables rate limiting
+	rateLimiter          *ipRateLimiter
+	signupHelpersEnabled bool
+	// When set, POST /api/users refuses to create accounts
+	signupsDisabled bool
+	// Wrap JSON responses in data/error envelopes
+	envelopeResponses bool
 }
 
 // Structures for JSON handling
@@ -31,230 +136,2157 @@ type ErrorResponse struct {
 }
 
 type User struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Email     string    `json:"email"`
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Email       string    `json:"email"`
+	IsChirpyRed bool      `json:"is_chirpy_red"`
 }
 
 type UserRequest struct {
-	Email string `json:"email"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type LoginResponse struct {
+	User
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+type RefreshResponse struct {
+	Token string `json:"token"`
+}
+
+// PolkaWebhookRequest is the event payload sent by the Polka payment provider
+type PolkaWebhookRequest struct {
+	Event string `json:"event"`
+	Data  struct {
+		UserID uuid.UUID `json:"user_id"`
+	} `json:"data"`
+}
+
+type LoginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type ReadOnlyRequest struct {
+	ReadOnly bool `json:"read_only"`
+}
+
+type ReadOnlyResponse struct {
+	ReadOnly bool `json:"read_only"`
+}
+
+type EmailAvailabilityResponse struct {
+	Available bool `json:"available"`
 }
 
 type Chirp struct {
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Body      string     `json:"body"`
+	UserID    uuid.UUID  `json:"user_id"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	IsFlagged bool       `json:"is_flagged"`
+	Author    *User      `json:"author,omitempty"`
+	// Set on quote-reposts; QuotedChirp is only hydrated one level deep
+	QuotedChirpID *uuid.UUID `json:"quoted_chirp_id,omitempty"`
+	QuotedChirp   *Chirp     `json:"quoted_chirp,omitempty"`
+}
+
+type ChirpPage struct {
+	Chirps     []Chirp `json:"chirps"`
+	Page       int     `json:"page"`
+	PerPage    int     `json:"per_page"`
+	TotalPages int     `json:"total_pages"`
+	Total      int64   `json:"total"`
+}
+
+type ChirpVersion struct {
 	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	UserID    uuid.UUID `json:"user_id"`
+}
+
+type ChirpContext struct {
+	Before []Chirp `json:"before"`
+	Chirp  Chirp   `json:"chirp"`
+	After  []Chirp `json:"after"`
+}
+
+type ChirpsExistRequest struct {
+	IDs []string `json:"ids"`
+}
+
+type ChirpsExistResponse struct {
+	Existing []uuid.UUID `json:"existing"`
+	Missing  []uuid.UUID `json:"missing"`
+}
+
+type UpdateChirpRequest struct {
+	Body string `json:"body"`
 }
 
 type CreateChirpRequest struct {
-	Body   string    `json:"body"`
-	UserID uuid.UUID `json:"user_id"`
+	Body string `json:"body"`
+	// Optional lifetime as a Go duration string, e.g. "90m"
+	ExpiresIn string `json:"expires_in,omitempty"`
+	// Optional chirp being quote-reposted
+	QuotedChirpID *uuid.UUID `json:"quoted_chirp_id,omitempty"`
+}
+
+// Helper function to convert a database chirp to the response type
+func databaseChirpToChirp(dbChirp database.Chirp) Chirp {
+	chirp := Chirp{
+		ID:        dbChirp.ID,
+		CreatedAt: dbChirp.CreatedAt,
+		UpdatedAt: dbChirp.UpdatedAt,
+		Body:      dbChirp.Body,
+		UserID:    dbChirp.UserID,
+		IsFlagged: dbChirp.IsFlagged,
+	}
+	if dbChirp.ExpiresAt.Valid {
+		chirp.ExpiresAt = &dbChirp.ExpiresAt.Time
+	}
+	if dbChirp.QuotedChirpID.Valid {
+		chirp.QuotedChirpID = &dbChirp.QuotedChirpID.UUID
+	}
+	return chirp
+}
+
+// hydrateQuotedChirps embeds the chirp each quote-repost references. The
+// embedded chirps keep only their own quoted_chirp_id, so nesting stops
+// after one level.
+func (cfg *apiConfig) hydrateQuotedChirps(ctx context.Context, chirps []Chirp) error {
+	var ids []uuid.UUID
+	for _, chirp := range chirps {
+		if chirp.QuotedChirpID != nil {
+			ids = append(ids, *chirp.QuotedChirpID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	quoted, err := cfg.db.GetChirpsByIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	byID := make(map[uuid.UUID]Chirp, len(quoted))
+	for _, dbChirp := range quoted {
+		byID[dbChirp.ID] = databaseChirpToChirp(dbChirp)
+	}
+	for i := range chirps {
+		if chirps[i].QuotedChirpID == nil {
+			continue
+		}
+		// Expired quoted chirps are left out rather than embedded
+		if q, ok := byID[*chirps[i].QuotedChirpID]; ok {
+			chirps[i].QuotedChirp = &q
+		}
+	}
+	return nil
+}
+
+// ClientConfig is the non-secret subset of settings exposed to frontends
+type ClientConfig struct {
+	MaxChirpLength int    `json:"max_chirp_length"`
+	ProfanityMask  string `json:"profanity_mask"`
+	URLIDFormat    string `json:"url_id_format"`
+	SignupsEnabled bool   `json:"signups_enabled"`
+	// Largest limit accepted by GET /api/chirps
+	PageSizeMax int `json:"page_size_max"`
+}
+
+// MetricsResponse is the JSON form of the hit counters served by /api/metrics
+type MetricsResponse struct {
+	FileserverHits int32            `json:"fileserver_hits"`
+	Endpoints      map[string]int64 `json:"endpoints"`
+}
+
+// HealthResponse is returned by the liveness and readiness probes
+type HealthResponse struct {
+	Status string `json:"status"`
+}
+
+// Capabilities lists which optional features this deployment has enabled
+type Capabilities struct {
+	SignupHelpers      bool `json:"signup_helpers"`
+	BannedEmailDomains bool `json:"banned_email_domains"`
+	ChirpLimit         bool `json:"chirp_limit"`
+	AccountAgeGate     bool `json:"account_age_gate"`
+	Base62IDs          bool `json:"base62_ids"`
+	ReadOnly           bool `json:"read_only"`
+	LoadShedding       bool `json:"load_shedding"`
+	PolkaWebhooks      bool `json:"polka_webhooks"`
+	RateLimiting       bool `json:"rate_limiting"`
+	Gzip               bool `json:"gzip"`
+	PrometheusMetrics  bool `json:"prometheus_metrics"`
+	AdminAuth          bool `json:"admin_auth"`
+	Likes              bool `json:"likes"`
+}
+
+type ResponseMeta struct {
+	RequestID string    `json:"request_id,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+type DataEnvelope struct {
+	Data interface{}  `json:"data"`
+	Meta ResponseMeta `json:"meta"`
+}
+
+type ErrorEnvelope struct {
+	Error string       `json:"error"`
+	Meta  ResponseMeta `json:"meta"`
+}
+
+// envelopeWriter marks a response whose JSON should be wrapped in a
+// data/error envelope; middlewareEnvelope installs it
+type envelopeWriter struct {
+	http.ResponseWriter
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (ew envelopeWriter) Unwrap() http.ResponseWriter {
+	return ew.ResponseWriter
+}
+
+// wantsEnvelope reports whether w, or a writer it wraps, is an envelopeWriter
+func wantsEnvelope(w http.ResponseWriter) bool {
+	for {
+		if _, ok := w.(envelopeWriter); ok {
+			return true
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return false
+		}
+		w = u.Unwrap()
+	}
 }
 
 // Helper functions for HTTP responses
 func respondWithError(w http.ResponseWriter, code int, msg string) {
-	respondWithJSON(w, code, ErrorResponse{Error: msg})
+	if wantsEnvelope(w) {
+		writeJSON(w, code, ErrorEnvelope{Error: msg, Meta: responseMeta(w)})
+		return
+	}
+	writeJSON(w, code, ErrorResponse{Error: msg})
+}
+
+func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
+	if wantsEnvelope(w) {
+		payload = DataEnvelope{Data: payload, Meta: responseMeta(w)}
+	}
+	writeJSON(w, code, payload)
+}
+
+// respondWithDecodeError reports a request body that failed to decode,
+// using 413 when it ran past the size limit and naming unknown fields
+func respondWithDecodeError(w http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		respondWithError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+		return
+	}
+	// encoding/json has no typed error for fields rejected by
+	// DisallowUnknownFields, only this message
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		respondWithError(w, http.StatusBadRequest, "Unknown field "+field)
+		return
+	}
+	respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+}
+
+func writeJSON(w http.ResponseWriter, code int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(payload)
+}
+
+func responseMeta(w http.ResponseWriter) ResponseMeta {
+	return ResponseMeta{
+		RequestID: w.Header().Get(requestIDHeader),
+		Timestamp: time.Now().UTC(),
+	}
+}
+
+// Helper functions for content negotiation on chirp listings
+func wantsHTML(r *http.Request) bool {
+	// An explicit format wins, so browsers can still ask for JSON
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format == "html"
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// chirpPageLinks holds the previous/next URLs rendered under an HTML listing;
+// an empty string means there is no such page.
+type chirpPageLinks struct {
+	Prev string
+	Next string
+}
+
+// pageLinks builds offset links for the page [offset, offset+limit) of total,
+// keeping the rest of the request's query intact.
+func pageLinks(r *http.Request, limit, offset int, total int64) chirpPageLinks {
+	link := func(offset int) string {
+		query := r.URL.Query()
+		query.Set("limit", strconv.Itoa(limit))
+		query.Set("offset", strconv.Itoa(offset))
+		return r.URL.Path + "?" + query.Encode()
+	}
+
+	var links chirpPageLinks
+	if offset > 0 && limit > 0 {
+		links.Prev = link(max(offset-limit, 0))
+	}
+	if limit > 0 && int64(offset+limit) < total {
+		links.Next = link(offset + limit)
+	}
+	return links
+}
+
+// pageNumberLinks is pageLinks for page-number pagination
+func pageNumberLinks(r *http.Request, page, perPage, totalPages int) chirpPageLinks {
+	link := func(page int) string {
+		query := r.URL.Query()
+		query.Set("page", strconv.Itoa(page))
+		query.Set("per_page", strconv.Itoa(perPage))
+		return r.URL.Path + "?" + query.Encode()
+	}
+
+	var links chirpPageLinks
+	if page > 1 {
+		// From past the end, step back to the last page there is
+		links.Prev = link(max(min(page-1, totalPages), 1))
+	}
+	if page < totalPages {
+		links.Next = link(page + 1)
+	}
+	return links
+}
+
+// respondWithChirps encodes a listing as protobuf, HTML or JSON depending on
+// the request. Page-number listings pass page so JSON and protobuf clients
+// also get the page metadata; otherwise JSON is the bare list.
+func respondWithChirps(w http.ResponseWriter, r *http.Request, code int, chirps []Chirp, links chirpPageLinks, page *ChirpPage) {
+	if wantsProtobuf(r) {
+		if page != nil {
+			respondWithProtobuf(w, code, chirpPageToProto(*page))
+			return
+		}
+		respondWithProtobuf(w, code, chirpsToProto(chirps))
+		return
+	}
+	if !wantsHTML(r) {
+		if page != nil {
+			respondWithJSON(w, code, page)
+			return
+		}
+		respondWithJSON(w, code, chirps)
+		return
+	}
+
+	// Render into a buffer first so a template error can still become a 500
+	var buf bytes.Buffer
+	data := struct {
+		Chirps []Chirp
+		Links  chirpPageLinks
+	}{chirps, links}
+	if err := chirpsTemplate.Execute(&buf, data); err != nil {
+		slog.Error("error rendering chirps", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error rendering chirps")
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(code)
+	w.Write(buf.Bytes())
+}
+
+func (cfg *apiConfig) now() time.Time {
+	if cfg.clock != nil {
+		return cfg.clock()
+	}
+	return time.Now()
+}
+
+// Helper function to generate IDs for new rows
+func (cfg *apiConfig) newID() uuid.UUID {
+	if cfg.uuidVersion == 7 {
+		// Time-ordered IDs keep primary key inserts at the end of the index
+		return uuid.Must(uuid.NewV7())
+	}
+	return uuid.New()
+}
+
+// Helper function to read the sort query parameter; asc is the default
+func parseSortDesc(r *http.Request) (bool, error) {
+	switch r.URL.Query().Get("sort") {
+	case "", "asc":
+		return false, nil
+	case "desc":
+		return true, nil
+	default:
+		return false, errors.New("sort must be either asc or desc")
+	}
+}
+
+// Helper function to read the limit and offset query parameters
+func parseLimitOffset(r *http.Request) (limit, offset int, err error) {
+	limit = defaultChirpsLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > maxChirpsLimit {
+			return 0, 0, fmt.Errorf("limit must be between 0 and %d", maxChirpsLimit)
+		}
+		limit = n
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, 0, errors.New("offset must be a non-negative integer")
+		}
+		offset = n
+	}
+	return limit, offset, nil
+}
+
+// Helper function to read the optional author_id filter
+func parseAuthorID(r *http.Request) (uuid.NullUUID, error) {
+	if !r.URL.Query().Has("author_id") {
+		return uuid.NullUUID{}, nil
+	}
+	id, err := uuid.Parse(r.URL.Query().Get("author_id"))
+	if err != nil {
+		return uuid.NullUUID{}, errors.New("Invalid author_id format")
+	}
+	return uuid.NullUUID{UUID: id, Valid: true}, nil
+}
+
+// Helper functions for chirp IDs in URLs
+func encodeBase62ID(id uuid.UUID) string {
+	return new(big.Int).SetBytes(id[:]).Text(62)
+}
+
+func decodeBase62ID(s string) (uuid.UUID, error) {
+	n, ok := new(big.Int).SetString(s, 62)
+	if !ok || n.Sign() < 0 || n.BitLen() > 128 {
+		return uuid.Nil, errors.New("invalid base62 ID")
+	}
+	var id uuid.UUID
+	n.FillBytes(id[:])
+	return id, nil
+}
+
+// parseChirpID accepts either a full UUID or its base62 form
+func parseChirpID(s string) (uuid.UUID, error) {
+	if id, err := uuid.Parse(s); err == nil {
+		return id, nil
+	}
+	return decodeBase62ID(s)
+}
+
+func (cfg *apiConfig) chirpURL(id uuid.UUID) string {
+	if cfg.urlIDFormat == "base62" {
+		return "/api/chirps/" + encodeBase62ID(id)
+	}
+	return "/api/chirps/" + id.String()
+}
+
+// escapeLikePattern makes % and _ in a search term match literally
+func escapeLikePattern(term string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(term)
+}
+
+// validateEmail accepts only a bare address such as "a@example.com",
+// rejecting empty strings and display-name forms
+func validateEmail(email string) error {
+	if email == "" {
+		return errors.New("invalid email format")
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return errors.New("invalid email format")
+	}
+	return nil
+}
+
+// Helper function to normalize and validate an email address; emails are
+// stored lowercased so lookups and the unique constraint ignore case
+func normalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	if err := validateEmail(email); err != nil {
+		return "", err
+	}
+	return strings.ToLower(email), nil
+}
+
+// Helper functions for the banned email domain list
+func parseBannedEmailDomains(raw string) map[string]struct{} {
+	domains := make(map[string]struct{})
+	for _, domain := range strings.Split(raw, ",") {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if domain != "" {
+			domains[domain] = struct{}{}
+		}
+	}
+	return domains
+}
+
+func (cfg *apiConfig) isEmailDomainBanned(email string) bool {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(email[at+1:])
+	if _, ok := cfg.bannedEmailDomains[domain]; ok {
+		return true
+	}
+
+	// Walk up the parent domains looking for a wildcard entry
+	for {
+		dot := strings.Index(domain, ".")
+		if dot < 0 {
+			return false
+		}
+		domain = domain[dot+1:]
+		if _, ok := cfg.bannedEmailDomains["*."+domain]; ok {
+			return true
+		}
+	}
+}
+
+func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg.fileserverHits.Add(1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// responseWriter records the status code a handler sends
+type responseWriter struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+}
+
+func (rw *responseWriter) WriteHeader(code int) {
+	if !rw.wroteHeader {
+		rw.statusCode = code
+		rw.wroteHeader = true
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	// An implicit header means 200, which is already the default
+	rw.wroteHeader = true
+	return rw.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// middlewareLogging logs every request once it completes
+func (cfg *apiConfig) middlewareLogging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		cfg.inFlight.Add(1)
+		defer cfg.inFlight.Add(-1)
+		next.ServeHTTP(rw, r)
+		elapsed := time.Since(start)
+
+		cfg.logger.Info("request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rw.statusCode,
+			"duration", elapsed,
+			"request_id", rw.Header().Get(requestIDHeader),
+		)
+		cfg.countEndpointHit(r)
+		if cfg.metrics != nil {
+			cfg.metrics.observe(r, rw.statusCode, elapsed)
+		}
+	})
+}
+
+// middlewareRequestID tags every response with a request ID, reusing the
+// caller's when it looks like a UUID
+func middlewareRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(requestIDHeader)
+		if _, err := uuid.Parse(requestID); err != nil {
+			requestID = uuid.New().String()
+		}
+		w.Header().Set(requestIDHeader, requestID)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// middlewareAdminAuth requires HTTP Basic credentials matching ADMIN_USER
+// and ADMIN_PASS. Dev without credentials configured is left open; any
+// other environment without them refuses everyone.
+func (cfg *apiConfig) middlewareAdminAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		configured := cfg.adminUser != "" && cfg.adminPass != ""
+		if !configured && cfg.platform == "dev" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user, pass, ok := r.BasicAuth()
+		// Compare both fields every time so timing doesn't reveal which was wrong
+		userMatch := subtle.ConstantTimeCompare([]byte(user), []byte(cfg.adminUser))
+		passMatch := subtle.ConstantTimeCompare([]byte(pass), []byte(cfg.adminPass))
+		if !configured || !ok || userMatch&passMatch != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="chirpy admin", charset="UTF-8"`)
+			respondWithError(w, http.StatusUnauthorized, "Admin credentials required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// middlewareMaxBodySize caps how much of a request body handlers may read
+func (cfg *apiConfig) middlewareMaxBodySize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, cfg.maxBodyBytes)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (cfg *apiConfig) middlewareConcurrencyLimit(next http.Handler) http.Handler {
+	if cfg.maxConcurrentRequests <= 0 {
+		return next
+	}
+
+	slots := make(chan struct{}, cfg.maxConcurrentRequests)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Health checks must keep answering while we shed load
+		if r.URL.Path == "/api/healthz" || r.URL.Path == "/api/readyz" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		select {
+		case slots <- struct{}{}:
+			defer func() { <-slots }()
+			next.ServeHTTP(w, r)
+		default:
+			w.Header().Set("Retry-After", "1")
+			respondWithError(w, http.StatusServiceUnavailable, "Server is too busy")
+		}
+	})
+}
+
+// middlewareEnvelope opts the responses below it into data/error envelopes
+// when ENVELOPE is enabled
+func (cfg *apiConfig) middlewareEnvelope(next http.Handler) http.Handler {
+	if !cfg.envelopeResponses {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(envelopeWriter{w}, r)
+	})
+}
+
+// readOnlySafeRoutes are non-GET routes that never write, so read-only mode
+// lets them through
+var readOnlySafeRoutes = map[string]bool{
+	"POST /api/chirps/exists": true,
 }
 
-func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(code)
-	json.NewEncoder(w).Encode(payload)
+func (cfg *apiConfig) middlewareReadOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			if readOnlySafeRoutes[r.Method+" "+r.URL.Path] {
+				break
+			}
+			// Admin routes stay writable so read-only mode can be switched off
+			if cfg.readOnly.Load() && !strings.HasPrefix(r.URL.Path, "/admin/") {
+				respondWithError(w, http.StatusServiceUnavailable, "service is read-only")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (cfg *apiConfig) adminMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, `<html>
+  <body>
+    <h1>Welcome, Chirpy Admin</h1>
+    <p>Chirpy has been visited %d times!</p>
+  </body>
+</html>`, cfg.fileserverHits.Load())
+}
+
+// metricsHandler is the machine-readable counterpart of /admin/metrics
+func (cfg *apiConfig) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, MetricsResponse{
+		FileserverHits: cfg.fileserverHits.Load(),
+		Endpoints:      cfg.endpointHitCounts(),
+	})
+}
+
+// adminMetricsResetHandler zeroes the hit counter without touching any data,
+// so unlike adminResetHandler it is allowed outside dev
+func (cfg *apiConfig) adminMetricsResetHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.fileserverHits.Store(0)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (cfg *apiConfig) adminResetHandler(w http.ResponseWriter, r *http.Request) {
+	// Check if platform is dev
+	if cfg.platform != "dev" {
+		respondWithError(w, http.StatusForbidden, "This endpoint is only available in development")
+		return
+	}
+
+	// Reset hits counter
+	cfg.fileserverHits.Store(0)
+
+	// Delete all users
+	err := cfg.db.DeleteAllUsers(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error deleting users")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (cfg *apiConfig) adminReadOnlyHandler(w http.ResponseWriter, r *http.Request) {
+	var req ReadOnlyRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	cfg.readOnly.Store(req.ReadOnly)
+	cfg.logger.Info("read-only mode changed", "read_only", req.ReadOnly)
+
+	respondWithJSON(w, http.StatusOK, ReadOnlyResponse{ReadOnly: cfg.readOnly.Load()})
+}
+
+// healthzHandler is the liveness probe; it never touches the database so a
+// brief outage doesn't get the process restarted. Probe bodies are never
+// enveloped.
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, HealthResponse{Status: "ok"})
+}
+
+// readyzHandler reports unhealthy when the database can't be reached, so
+// load balancers stop routing to this instance
+func (cfg *apiConfig) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+
+	if err := cfg.dbConn.PingContext(ctx); err != nil {
+		cfg.logger.Error("readiness check failed", "err", err)
+		writeJSON(w, http.StatusServiceUnavailable, HealthResponse{Status: "unhealthy"})
+		return
+	}
+	writeJSON(w, http.StatusOK, HealthResponse{Status: "ok"})
+}
+
+func (cfg *apiConfig) clientConfigHandler(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, ClientConfig{
+		MaxChirpLength: maxChirpLength,
+		ProfanityMask:  profanityMask,
+		URLIDFormat:    cfg.urlIDFormat,
+		SignupsEnabled: !cfg.signupsDisabled,
+		PageSizeMax:    maxChirpsLimit,
+	})
+}
+
+// sweepExpiredChirps deletes expired chirps every interval until ctx is done.
+// Reads already hide expired chirps, so this only reclaims the rows.
+func (cfg *apiConfig) sweepExpiredChirps(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := cfg.db.DeleteExpiredChirps(ctx)
+			if err != nil {
+				cfg.logger.Error("error sweeping expired chirps", "err", err)
+				continue
+			}
+			if n > 0 {
+				cfg.logger.Info("swept expired chirps", "count", n)
+			}
+		}
+	}
+}
+
+func (cfg *apiConfig) capabilitiesHandler(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, Capabilities{
+		SignupHelpers:      cfg.signupHelpersEnabled,
+		BannedEmailDomains: len(cfg.bannedEmailDomains) > 0,
+		ChirpLimit:         cfg.maxChirpsPerUser > 0,
+		AccountAgeGate:     cfg.minAccountAgeToPost > 0,
+		Base62IDs:          cfg.urlIDFormat == "base62",
+		ReadOnly:           cfg.readOnly.Load(),
+		LoadShedding:       cfg.maxConcurrentRequests > 0,
+		PolkaWebhooks:      true,
+		RateLimiting:       cfg.rateLimiter != nil,
+		Gzip:               true,
+		PrometheusMetrics:  cfg.metrics != nil,
+		AdminAuth:          cfg.adminUser != "" && cfg.adminPass != "",
+		Likes:              true,
+	})
+}
+
+func (cfg *apiConfig) createUserHandler(w http.ResponseWriter, r *http.Request) {
+	if cfg.signupsDisabled {
+		respondWithError(w, http.StatusForbidden, "Signups are disabled")
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	var userReq UserRequest
+	err := decoder.Decode(&userReq)
+	if err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	userReq.Email, err = normalizeEmail(userReq.Email)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid email format")
+		return
+	}
+
+	if userReq.Password == "" {
+		respondWithError(w, http.StatusBadRequest, "Password is required")
+		return
+	}
+
+	if cfg.isEmailDomainBanned(userReq.Email) {
+		respondWithError(w, http.StatusBadRequest, "email domain not allowed")
+		return
+	}
+
+	// Hash the password; only the hash is ever stored
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(userReq.Password), bcrypt.DefaultCost)
+	if err != nil {
+		cfg.logger.Error("error hashing password", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error creating user")
+		return
+	}
+
+	// Create user in database
+	params := database.CreateUserParams{
+		ID:             cfg.newID(),
+		Email:          userReq.Email,
+		CreatedAt:      time.Now().UTC(),
+		UpdatedAt:      time.Now().UTC(),
+		HashedPassword: string(hashedPassword),
+	}
+	var dbUser database.User
+	err = cfg.withDBRetry(r.Context(), func() error {
+		var err error
+		dbUser, err = cfg.db.CreateUser(r.Context(), params)
+		return err
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			respondWithError(w, http.StatusConflict, "email already in use")
+			return
+		}
+		cfg.logger.Error("error creating user", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error creating user")
+		return
+	}
+
+	// Map database user to response user
+	user := User{
+		ID:          dbUser.ID,
+		CreatedAt:   dbUser.CreatedAt,
+		UpdatedAt:   dbUser.UpdatedAt,
+		Email:       dbUser.Email,
+		IsChirpyRed: dbUser.IsChirpyRed,
+	}
+
+	respondWithJSON(w, http.StatusCreated, user)
+}
+
+func (cfg *apiConfig) updateUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid or missing access token")
+		return
+	}
+
+	var userReq UserRequest
+	err = json.NewDecoder(r.Body).Decode(&userReq)
+	if err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	userReq.Email, err = normalizeEmail(userReq.Email)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid email format")
+		return
+	}
+
+	if userReq.Password == "" {
+		respondWithError(w, http.StatusBadRequest, "Password is required")
+		return
+	}
+
+	if cfg.isEmailDomainBanned(userReq.Email) {
+		respondWithError(w, http.StatusBadRequest, "email domain not allowed")
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(userReq.Password), bcrypt.DefaultCost)
+	if err != nil {
+		cfg.logger.Error("error hashing password", "user_id", userID, "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error updating user")
+		return
+	}
+
+	dbUser, err := cfg.db.UpdateUser(r.Context(), database.UpdateUserParams{
+		ID:             userID,
+		Email:          userReq.Email,
+		HashedPassword: string(hashedPassword),
+		UpdatedAt:      time.Now().UTC(),
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			// The token outlived its user
+			respondWithError(w, http.StatusUnauthorized, "Invalid or missing access token")
+			return
+		}
+		if isUniqueViolation(err) {
+			respondWithError(w, http.StatusConflict, "email already in use")
+			return
+		}
+		cfg.logger.Error("error updating user", "user_id", userID, "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error updating user")
+		return
+	}
+
+	// Map database user to response user
+	user := User{
+		ID:          dbUser.ID,
+		CreatedAt:   dbUser.CreatedAt,
+		UpdatedAt:   dbUser.UpdatedAt,
+		Email:       dbUser.Email,
+		IsChirpyRed: dbUser.IsChirpyRed,
+	}
+
+	respondWithJSON(w, http.StatusOK, user)
+}
+
+func (cfg *apiConfig) loginHandler(w http.ResponseWriter, r *http.Request) {
+	var req LoginRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&req)
+	if err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	// Match the form the email was stored in at signup
+	email, err := normalizeEmail(req.Email)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password")
+		return
+	}
+
+	dbUser, err := cfg.db.GetUserByEmail(r.Context(), email)
+	if err != nil && err != sql.ErrNoRows {
+		cfg.logger.Error("error looking up user by email", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error logging in")
+		return
+	}
+
+	// Always run a bcrypt comparison, even for unknown emails
+	hash := dummyPasswordHash
+	if err == nil {
+		hash = dbUser.HashedPassword
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(req.Password)) != nil || err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password")
+		return
+	}
+
+	token, err := makeJWT(dbUser.ID, cfg.jwtSecret, accessTokenExpiresIn)
+	if err != nil {
+		cfg.logger.Error("error creating access token", "user_id", dbUser.ID, "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error logging in")
+		return
+	}
+
+	refreshToken, err := makeRefreshToken()
+	if err != nil {
+		cfg.logger.Error("error creating refresh token", "user_id", dbUser.ID, "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error logging in")
+		return
+	}
+	now := time.Now().UTC()
+	_, err = cfg.db.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
+		Token:     refreshToken,
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    dbUser.ID,
+		ExpiresAt: now.Add(refreshTokenExpiresIn),
+	})
+	if err != nil {
+		cfg.logger.Error("error storing refresh token", "user_id", dbUser.ID, "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error logging in")
+		return
+	}
+
+	// Map database user to response user
+	response := LoginResponse{
+		User: User{
+			ID:          dbUser.ID,
+			CreatedAt:   dbUser.CreatedAt,
+			UpdatedAt:   dbUser.UpdatedAt,
+			Email:       dbUser.Email,
+			IsChirpyRed: dbUser.IsChirpyRed,
+		},
+		Token:        token,
+		RefreshToken: refreshToken,
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func (cfg *apiConfig) refreshHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := getBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or malformed refresh token")
+		return
+	}
+
+	refreshToken, err := cfg.db.GetRefreshToken(r.Context(), token)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusUnauthorized, "Invalid refresh token")
+			return
+		}
+		cfg.logger.Error("error getting refresh token", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error refreshing token")
+		return
+	}
+	if refreshToken.RevokedAt.Valid || time.Now().UTC().After(refreshToken.ExpiresAt) {
+		respondWithError(w, http.StatusUnauthorized, "Invalid refresh token")
+		return
+	}
+
+	accessToken, err := makeJWT(refreshToken.UserID, cfg.jwtSecret, accessTokenExpiresIn)
+	if err != nil {
+		cfg.logger.Error("error creating access token", "user_id", refreshToken.UserID, "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error refreshing token")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, RefreshResponse{Token: accessToken})
+}
+
+func (cfg *apiConfig) revokeHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := getBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or malformed refresh token")
+		return
+	}
+
+	// Revoking an unknown or already revoked token is a no-op
+	err = cfg.db.RevokeRefreshToken(r.Context(), database.RevokeRefreshTokenParams{
+		Token:     token,
+		RevokedAt: sql.NullTime{Time: time.Now().UTC(), Valid: true},
+	})
+	if err != nil {
+		cfg.logger.Error("error revoking refresh token", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error revoking token")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) polkaWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	// Only Polka knows the shared key
+	apiKey, err := getAPIKey(r.Header)
+	if err != nil || subtle.ConstantTimeCompare([]byte(apiKey), []byte(cfg.polkaKey)) != 1 {
+		respondWithError(w, http.StatusUnauthorized, "Invalid or missing API key")
+		return
+	}
+
+	var req PolkaWebhookRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	// Acknowledge events we don't handle so Polka stops retrying them
+	if req.Event != "user.upgraded" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	rows, err := cfg.db.UpgradeUserToChirpyRed(r.Context(), database.UpgradeUserToChirpyRedParams{
+		ID:        req.Data.UserID,
+		UpdatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		cfg.logger.Error("error upgrading user", "user_id", req.Data.UserID, "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error upgrading user")
+		return
+	}
+	if rows == 0 {
+		respondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) emailAvailabilityHandler(w http.ResponseWriter, r *http.Request) {
+	email, err := normalizeEmail(r.URL.Query().Get("email"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid email format")
+		return
+	}
+
+	_, err = cfg.db.GetUserByEmail(r.Context(), email)
+	if err != nil && err != sql.ErrNoRows {
+		cfg.logger.Error("error looking up user by email", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error checking email availability")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, EmailAvailabilityResponse{Available: err == sql.ErrNoRows})
+}
+
+func (cfg *apiConfig) getUserByIDHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID format")
+		return
+	}
+
+	dbUser, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		cfg.logger.Error("error getting user", "user_id", userID, "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting user")
+		return
+	}
+
+	// Map database user to response user
+	user := User{
+		ID:          dbUser.ID,
+		CreatedAt:   dbUser.CreatedAt,
+		UpdatedAt:   dbUser.UpdatedAt,
+		Email:       dbUser.Email,
+		IsChirpyRed: dbUser.IsChirpyRed,
+	}
+
+	respondWithJSON(w, http.StatusOK, user)
+}
+
+func (cfg *apiConfig) getUserWordStatsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID format")
+		return
+	}
+
+	limit := defaultWordStatsLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxWordStatsLimit {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxWordStatsLimit))
+			return
+		}
+		limit = n
+	}
+
+	// Make sure the user exists so unknown IDs get a 404 rather than []
+	_, err = cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		cfg.logger.Error("error getting user", "user_id", userID, "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting user")
+		return
+	}
+
+	// Only analyze the user's most recent chirps
+	bodies, err := cfg.db.GetChirpBodiesByUser(r.Context(), database.GetChirpBodiesByUserParams{
+		UserID: userID,
+		Limit:  maxWordStatsChirps,
+	})
+	if err != nil {
+		cfg.logger.Error("error getting chirps", "user_id", userID, "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting chirps")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, topWords(bodies, limit))
+}
+
+func (cfg *apiConfig) getUserChirpCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID format")
+		return
+	}
+
+	start, err := time.Parse("2006-01", r.URL.Query().Get("month"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "month must be in YYYY-MM format")
+		return
+	}
+
+	_, err = cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		cfg.logger.Error("error getting user", "user_id", userID, "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting user")
+		return
+	}
+
+	chirps, err := cfg.db.GetChirpsByUserBetween(r.Context(), database.GetChirpsByUserBetweenParams{
+		UserID:    userID,
+		StartTime: start,
+		EndTime:   start.AddDate(0, 1, 0),
+	})
+	if err != nil {
+		cfg.logger.Error("error getting chirps", "user_id", userID, "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting chirps")
+		return
+	}
+
+	// Group by UTC day; days without chirps are left out
+	response := make(map[string][]Chirp)
+	for _, dbChirp := range chirps {
+		day := dbChirp.CreatedAt.Format("2006-01-02")
+		response[day] = append(response[day], databaseChirpToChirp(dbChirp))
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+var errChirpLimitReached = errors.New("chirp limit reached")
+
+// insertChirpWithinCap creates the chirp, enforcing the lifetime cap when one
+// is set. The author's row is locked for the count and insert so concurrent
+// posts can't both slip under the cap.
+func (cfg *apiConfig) insertChirpWithinCap(ctx context.Context, params database.CreateChirpParams) (database.Chirp, error) {
+	if cfg.maxChirpsPerUser <= 0 {
+		return cfg.db.CreateChirp(ctx, params)
+	}
+
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	if _, err := qtx.LockUser(ctx, params.UserID); err != nil {
+		return database.Chirp{}, err
+	}
+	count, err := qtx.CountChirpsByUser(ctx, params.UserID)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	if count >= int64(cfg.maxChirpsPerUser) {
+		return database.Chirp{}, errChirpLimitReached
+	}
+
+	chirp, err := qtx.CreateChirp(ctx, params)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	return chirp, tx.Commit()
+}
+
+func (cfg *apiConfig) createChirpHandler(w http.ResponseWriter, r *http.Request) {
+	// The author comes from the verified token, never the request body
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid or missing access token")
+		return
+	}
+
+	var req CreateChirpRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(&req)
+	if err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	// Validate chirp length
+	if len(req.Body) > maxChirpLength {
+		respondWithError(w, http.StatusBadRequest, "Chirp is too long")
+		return
+	}
+
+	// Validate the optional expiry
+	var expiresAt sql.NullTime
+	if req.ExpiresIn != "" {
+		lifetime, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || lifetime <= 0 || lifetime > maxChirpLifetime {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("expires_in must be a positive duration up to %s", maxChirpLifetime))
+			return
+		}
+		expiresAt = sql.NullTime{Time: cfg.now().UTC().Add(lifetime), Valid: true}
+	}
+
+	// Keep brand-new accounts from posting
+	if cfg.minAccountAgeToPost > 0 {
+		user, err := cfg.db.GetUserByID(r.Context(), userID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				respondWithError(w, http.StatusBadRequest, "User not found")
+				return
+			}
+			cfg.logger.Error("error getting user", "user_id", userID, "err", err)
+			respondWithError(w, http.StatusInternalServerError, "Error creating chirp")
+			return
+		}
+		if cfg.now().UTC().Sub(user.CreatedAt) < cfg.minAccountAgeToPost {
+			respondWithError(w, http.StatusForbidden, "account too new to post")
+			return
+		}
+	}
+
+	// A quote-repost must reference a live chirp
+	var quotedChirpID uuid.NullUUID
+	if req.QuotedChirpID != nil {
+		_, err := cfg.db.GetChirpByID(r.Context(), *req.QuotedChirpID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				respondWithError(w, http.StatusBadRequest, "Quoted chirp not found")
+				return
+			}
+			cfg.logger.Error("error getting quoted chirp", "chirp_id", *req.QuotedChirpID, "err", err)
+			respondWithError(w, http.StatusInternalServerError, "Error creating chirp")
+			return
+		}
+		quotedChirpID = uuid.NullUUID{UUID: *req.QuotedChirpID, Valid: true}
+	}
+
+	// Clean profanity according to the configured mode
+	cleanedBody, isFlagged, err := cfg.moderateChirp(req.Body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Chirp contains prohibited language")
+		return
+	}
+
+	// Create chirp in database
+	params := database.CreateChirpParams{
+		ID:            cfg.newID(),
+		CreatedAt:     time.Now().UTC(),
+		UpdatedAt:     time.Now().UTC(),
+		Body:          cleanedBody,
+		UserID:        userID,
+		ExpiresAt:     expiresAt,
+		IsFlagged:     isFlagged,
+		QuotedChirpID: quotedChirpID,
+	}
+	var chirp database.Chirp
+	err = cfg.withDBRetry(r.Context(), func() error {
+		var err error
+		chirp, err = cfg.insertChirpWithinCap(r.Context(), params)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, errChirpLimitReached) {
+			respondWithError(w, http.StatusForbidden, "chirp limit reached")
+			return
+		}
+		// The author was deleted after their token was issued: LockUser finds
+		// no row, or without a cap the insert trips the user_id foreign key
+		if constraint, ok := foreignKeyViolation(err); errors.Is(err, sql.ErrNoRows) || (ok && constraint == "chirps_user_id_fkey") {
+			respondWithError(w, http.StatusUnauthorized, "Invalid or missing access token")
+			return
+		}
+		cfg.logger.Error("error creating chirp", "user_id", userID, "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error creating chirp")
+		return
+	}
+
+	// Convert database chirp to response type
+	response := []Chirp{databaseChirpToChirp(chirp)}
+	err = cfg.hydrateQuotedChirps(r.Context(), response)
+	if err != nil {
+		cfg.logger.Error("error getting quoted chirp", "chirp_id", chirp.ID, "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error creating chirp")
+		return
+	}
+
+	w.Header().Set("Location", cfg.chirpURL(chirp.ID))
+	respondWithJSON(w, http.StatusCreated, response[0])
+}
+
+func (cfg *apiConfig) getChirpsHandler(w http.ResponseWriter, r *http.Request) {
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "json", "html":
+	default:
+		respondWithError(w, http.StatusBadRequest, "Unknown format value")
+		return
+	}
+
+	// Optionally narrow the listing to one author
+	authorID, err := parseAuthorID(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Optionally keep only chirps containing a term; empty means no filter
+	var search sql.NullString
+	if term := strings.TrimSpace(r.URL.Query().Get("search")); term != "" {
+		search = sql.NullString{String: escapeLikePattern(term), Valid: true}
+	}
+
+	// Page-number pagination returns an envelope instead of a bare list
+	if r.URL.Query().Has("page") || r.URL.Query().Has("per_page") {
+		if r.URL.Query().Has("limit") || r.URL.Query().Has("offset") {
+			respondWithError(w, http.StatusBadRequest, "limit and offset cannot be combined with page")
+			return
+		}
+		if r.URL.Query().Get("expand") != "" {
+			respondWithError(w, http.StatusBadRequest, "expand cannot be combined with page")
+			return
+		}
+		cfg.getChirpsPageHandler(w, r, authorID, search)
+		return
+	}
+
+	expand := r.URL.Query().Get("expand")
+	switch expand {
+	case "", "quoted":
+	case "author":
+		cfg.getChirpsWithAuthorHandler(w, r, authorID, search)
+		return
+	default:
+		respondWithError(w, http.StatusBadRequest, "Unknown expand value")
+		return
+	}
+
+	sortDesc, err := parseSortDesc(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	limit, offset, err := parseLimitOffset(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// The total lets clients build pagers without fetching everything
+	total, err := cfg.db.CountChirps(r.Context(), database.CountChirpsParams{
+		UserID: authorID,
+		Search: search,
+	})
+	if err != nil {
+		cfg.logger.Error("error counting chirps", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting chirps")
+		return
+	}
+	w.Header().Set("X-Total-Count", strconv.FormatInt(total, 10))
+
+	// Offsets past the end are simply empty
+	var chirps []database.Chirp
+	if int64(offset) < total {
+		chirps, err = cfg.db.GetChirpsPaginated(r.Context(), database.GetChirpsPaginatedParams{
+			UserID:     authorID,
+			Search:     search,
+			SortDesc:   sortDesc,
+			MaxResults: int32(limit),
+			Skip:       int32(offset),
+		})
+		if err != nil {
+			cfg.logger.Error("error getting chirps", "err", err)
+			respondWithError(w, http.StatusInternalServerError, "Error getting chirps")
+			return
+		}
+	}
+
+	// Convert database chirps to response type
+	response := make([]Chirp, len(chirps))
+	for i, dbChirp := range chirps {
+		response[i] = databaseChirpToChirp(dbChirp)
+	}
+
+	if expand == "quoted" {
+		err = cfg.hydrateQuotedChirps(r.Context(), response)
+		if err != nil {
+			cfg.logger.Error("error getting quoted chirps", "err", err)
+			respondWithError(w, http.StatusInternalServerError, "Error getting chirps")
+			return
+		}
+	}
+
+	respondWithChirps(w, r, http.StatusOK, response, pageLinks(r, limit, offset, total), nil)
+}
+
+func (cfg *apiConfig) getChirpsPageHandler(w http.ResponseWriter, r *http.Request, authorID uuid.NullUUID, search sql.NullString) {
+	query := r.URL.Query()
+	page := 1
+	if v := query.Get("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			respondWithError(w, http.StatusBadRequest, "page must be a positive integer")
+			return
+		}
+		page = n
+	}
+	perPage := defaultPerPage
+	if v := query.Get("per_page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPerPage {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("per_page must be between 1 and %d", maxPerPage))
+			return
+		}
+		perPage = n
+	}
+	// The offset is passed to the query as an int32
+	if int64(page-1)*int64(perPage) > math.MaxInt32 {
+		respondWithError(w, http.StatusBadRequest, "page is too large")
+		return
+	}
+
+	sortDesc, err := parseSortDesc(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	total, err := cfg.db.CountChirps(r.Context(), database.CountChirpsParams{
+		UserID: authorID,
+		Search: search,
+	})
+	if err != nil {
+		cfg.logger.Error("error counting chirps", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting chirps")
+		return
+	}
+
+	// Pages past the end are simply empty
+	var chirps []database.Chirp
+	if offset := int64(page-1) * int64(perPage); offset < total {
+		chirps, err = cfg.db.GetChirpsPaginated(r.Context(), database.GetChirpsPaginatedParams{
+			UserID:     authorID,
+			Search:     search,
+			SortDesc:   sortDesc,
+			MaxResults: int32(perPage),
+			Skip:       int32(offset),
+		})
+		if err != nil {
+			cfg.logger.Error("error getting chirps", "err", err)
+			respondWithError(w, http.StatusInternalServerError, "Error getting chirps")
+			return
+		}
+	}
+
+	// Convert database chirps to response type
+	response := ChirpPage{
+		Chirps:     make([]Chirp, len(chirps)),
+		Page:       page,
+		PerPage:    perPage,
+		TotalPages: int((total + int64(perPage) - 1) / int64(perPage)),
+		Total:      total,
+	}
+	for i, dbChirp := range chirps {
+		response.Chirps[i] = databaseChirpToChirp(dbChirp)
+	}
+
+	links := pageNumberLinks(r, page, perPage, response.TotalPages)
+	respondWithChirps(w, r, http.StatusOK, response.Chirps, links, &response)
+}
+
+func (cfg *apiConfig) getChirpIDsHandler(w http.ResponseWriter, r *http.Request) {
+	include := r.URL.Query().Get("include")
+	if include != "" && include != "updated_at" {
+		respondWithError(w, http.StatusBadRequest, "Unknown include value")
+		return
+	}
+
+	sortDesc, err := parseSortDesc(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	authorID, err := parseAuthorID(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rows, err := cfg.db.GetChirpIDs(r.Context(), database.GetChirpIDsParams{
+		UserID:   authorID,
+		SortDesc: sortDesc,
+	})
+	if err != nil {
+		cfg.logger.Error("error getting chirp IDs", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting chirps")
+		return
+	}
+
+	// Bare IDs by default; id/updated_at pairs for clients diffing edits
+	if include == "updated_at" {
+		versions := make([]ChirpVersion, len(rows))
+		for i, row := range rows {
+			versions[i] = ChirpVersion{ID: row.ID, UpdatedAt: row.UpdatedAt}
+		}
+		respondWithJSON(w, http.StatusOK, versions)
+		return
+	}
+
+	ids := make([]uuid.UUID, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+	}
+	respondWithJSON(w, http.StatusOK, ids)
+}
+
+func (cfg *apiConfig) getChirpsWithAuthorHandler(w http.ResponseWriter, r *http.Request, authorID uuid.NullUUID, search sql.NullString) {
+	sortDesc, err := parseSortDesc(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	limit, offset, err := parseLimitOffset(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	total, err := cfg.db.CountChirps(r.Context(), database.CountChirpsParams{
+		UserID: authorID,
+		Search: search,
+	})
+	if err != nil {
+		cfg.logger.Error("error counting chirps", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting chirps")
+		return
+	}
+	w.Header().Set("X-Total-Count", strconv.FormatInt(total, 10))
+
+	// Get one page of chirps joined with their authors
+	var chirps []database.GetChirpsWithAuthorRow
+	if int64(offset) < total {
+		chirps, err = cfg.db.GetChirpsWithAuthor(r.Context(), database.GetChirpsWithAuthorParams{
+			UserID:     authorID,
+			Search:     search,
+			SortDesc:   sortDesc,
+			MaxResults: int32(limit),
+			Skip:       int32(offset),
+		})
+		if err != nil {
+			cfg.logger.Error("error getting chirps", "err", err)
+			respondWithError(w, http.StatusInternalServerError, "Error getting chirps")
+			return
+		}
+	}
+
+	// Convert database rows to response type with the author embedded
+	response := make([]Chirp, len(chirps))
+	for i, row := range chirps {
+		response[i] = databaseChirpToChirp(row.Chirp)
+		response[i].Author = &User{
+			ID:          row.Chirp.UserID,
+			CreatedAt:   row.AuthorCreatedAt,
+			UpdatedAt:   row.AuthorUpdatedAt,
+			Email:       row.AuthorEmail,
+			IsChirpyRed: row.AuthorIsChirpyRed,
+		}
+	}
+
+	respondWithChirps(w, r, http.StatusOK, response, pageLinks(r, limit, offset, total), nil)
+}
+
+func (cfg *apiConfig) getRecentChirpsHandler(w http.ResponseWriter, r *http.Request) {
+	minutes := defaultRecentMinutes
+	if v := r.URL.Query().Get("minutes"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxRecentMinutes {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("minutes must be between 1 and %d", maxRecentMinutes))
+			return
+		}
+		minutes = n
+	}
+
+	// Get chirps created inside the window, newest first
+	since := cfg.now().UTC().Add(-time.Duration(minutes) * time.Minute)
+	chirps, err := cfg.db.GetChirpsSince(r.Context(), since)
+	if err != nil {
+		cfg.logger.Error("error getting recent chirps", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting chirps")
+		return
+	}
+
+	// Convert database chirps to response type
+	response := make([]Chirp, len(chirps))
+	for i, dbChirp := range chirps {
+		response[i] = databaseChirpToChirp(dbChirp)
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func (cfg *apiConfig) getChirpByIDHandler(w http.ResponseWriter, r *http.Request) {
+	// Get chirp ID from path parameter
+	chirpIDStr := r.PathValue("chirpID")
+
+	// Parse the UUID (or its base62 form)
+	chirpID, err := parseChirpID(chirpIDStr)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID format")
+		return
+	}
+
+	// Get chirp from database
+	chirp, err := cfg.db.GetChirpByID(r.Context(), chirpID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusNotFound, "Chirp not found")
+			return
+		}
+		cfg.logger.Error("error getting chirp", "chirp_id", chirpID, "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting chirp")
+		return
+	}
+
+	// Convert database chirp to response type
+	response := []Chirp{databaseChirpToChirp(chirp)}
+	err = cfg.hydrateQuotedChirps(r.Context(), response)
+	if err != nil {
+		cfg.logger.Error("error getting quoted chirp", "chirp_id", chirpID, "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting chirp")
+		return
+	}
+
+	if wantsProtobuf(r) {
+		respondWithProtobuf(w, http.StatusOK, chirpToProto(response[0]))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, response[0])
+}
+
+func (cfg *apiConfig) getChirpQuotesHandler(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := parseChirpID(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID format")
+		return
+	}
+
+	// The quoted chirp itself must still exist
+	_, err = cfg.db.GetChirpByID(r.Context(), chirpID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusNotFound, "Chirp not found")
+			return
+		}
+		cfg.logger.Error("error getting chirp", "chirp_id", chirpID, "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting quotes")
+		return
+	}
+
+	quotes, err := cfg.db.GetQuotesOfChirp(r.Context(), uuid.NullUUID{UUID: chirpID, Valid: true})
+	if err != nil {
+		cfg.logger.Error("error getting quotes", "chirp_id", chirpID, "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting quotes")
+		return
+	}
+
+	response := make([]Chirp, len(quotes))
+	for i, dbChirp := range quotes {
+		response[i] = databaseChirpToChirp(dbChirp)
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func (cfg *apiConfig) chirpsExistHandler(w http.ResponseWriter, r *http.Request) {
+	var req ChirpsExistRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+	if len(req.IDs) > maxExistsIDs {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("At most %d ids may be checked at once", maxExistsIDs))
+		return
+	}
+
+	ids := make([]uuid.UUID, len(req.IDs))
+	for i, idStr := range req.IDs {
+		ids[i], err = uuid.Parse(idStr)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid chirp ID format: %q", idStr))
+			return
+		}
+	}
+
+	// Look up the whole set in one query
+	found, err := cfg.db.GetExistingChirpIDs(r.Context(), ids)
+	if err != nil {
+		cfg.logger.Error("error checking chirp IDs", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error checking chirps")
+		return
+	}
+	existing := make(map[uuid.UUID]bool, len(found))
+	for _, id := range found {
+		existing[id] = true
+	}
+
+	// Split the request in its original order
+	response := ChirpsExistResponse{
+		Existing: make([]uuid.UUID, 0, len(found)),
+		Missing:  make([]uuid.UUID, 0, len(ids)-len(found)),
+	}
+	for _, id := range ids {
+		if existing[id] {
+			response.Existing = append(response.Existing, id)
+		} else {
+			response.Missing = append(response.Missing, id)
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
 }
 
-// Helper function to clean profanity
-func cleanProfanity(input string) string {
-	profaneWords := []string{"kerfuffle", "sharbert", "fornax"}
-	words := strings.Split(input, " ")
+func (cfg *apiConfig) deleteChirpHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid or missing access token")
+		return
+	}
+
+	chirpID, err := parseChirpID(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID format")
+		return
+	}
 
-	for i, word := range words {
-		wordLower := strings.ToLower(word)
-		for _, profane := range profaneWords {
-			if wordLower == profane {
-				words[i] = "****"
-				break
-			}
+	// Check existence before ownership so we don't reveal who owns what
+	chirp, err := cfg.db.GetChirpByID(r.Context(), chirpID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusNotFound, "Chirp not found")
+			return
 		}
+		cfg.logger.Error("error getting chirp", "chirp_id", chirpID, "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting chirp")
+		return
+	}
+	if chirp.UserID != userID {
+		respondWithError(w, http.StatusForbidden, "You can only delete your own chirps")
+		return
 	}
 
-	return strings.Join(words, " ")
-}
+	err = cfg.db.DeleteChirp(r.Context(), chirpID)
+	if err != nil {
+		cfg.logger.Error("error deleting chirp", "chirp_id", chirpID, "user_id", userID, "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error deleting chirp")
+		return
+	}
 
-func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		cfg.fileserverHits.Add(1)
-		next.ServeHTTP(w, r)
-	})
+	w.WriteHeader(http.StatusNoContent)
 }
 
-func (cfg *apiConfig) adminMetricsHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, `<html>
-  <body>
-    <h1>Welcome, Chirpy Admin</h1>
-    <p>Chirpy has been visited %d times!</p>
-  </body>
-</html>`, cfg.fileserverHits.Load())
-}
+// likeChirpHandler records that the caller likes a chirp; liking it again
+// is a no-op
+func (cfg *apiConfig) likeChirpHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.userIDFromRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid or missing access token")
+		return
+	}
 
-func (cfg *apiConfig) adminResetHandler(w http.ResponseWriter, r *http.Request) {
-	// Check if platform is dev
-	if cfg.platform != "dev" {
-		respondWithError(w, http.StatusForbidden, "This endpoint is only available in development")
+	chirpID, err := parseChirpID(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID format")
 		return
 	}
 
-	// Reset hits counter
-	cfg.fileserverHits.Store(0)
+	// Expired chirps can't be liked, and a 404 reads better than an FK error
+	if _, err := cfg.db.GetChirpByID(r.Context(), chirpID); err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusNotFound, "Chirp not found")
+			return
+		}
+		cfg.logger.Error("error getting chirp", "chirp_id", chirpID, "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting chirp")
+		return
+	}
 
-	// Delete all users
-	err := cfg.db.DeleteAllUsers(r.Context())
+	err = cfg.db.LikeChirp(r.Context(), database.LikeChirpParams{
+		UserID:    userID,
+		ChirpID:   chirpID,
+		CreatedAt: time.Now().UTC(),
+	})
+	if constraint, ok := foreignKeyViolation(err); ok {
+		// The user or chirp was deleted after the checks above
+		if constraint == "chirp_likes_user_id_fkey" {
+			respondWithError(w, http.StatusUnauthorized, "Invalid or missing access token")
+			return
+		}
+		respondWithError(w, http.StatusNotFound, "Chirp not found")
+		return
+	}
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error deleting users")
+		cfg.logger.Error("error liking chirp", "chirp_id", chirpID, "user_id", userID, "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error liking chirp")
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(http.StatusNoContent)
 }
 
-func (cfg *apiConfig) createUserHandler(w http.ResponseWriter, r *http.Request) {
-	decoder := json.NewDecoder(r.Body)
-	var userReq UserRequest
-	err := decoder.Decode(&userReq)
+// unlikeChirpHandler removes the caller's like, if there was one
+func (cfg *apiConfig) unlikeChirpHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.userIDFromRequest(r)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		respondWithError(w, http.StatusUnauthorized, "Invalid or missing access token")
 		return
 	}
 
-	// Create user in database
-	dbUser, err := cfg.db.CreateUser(r.Context(), database.CreateUserParams{
-		ID:        uuid.New(),
-		Email:     userReq.Email,
-		CreatedAt: time.Now().UTC(),
-		UpdatedAt: time.Now().UTC(),
-	})
+	chirpID, err := parseChirpID(r.PathValue("chirpID"))
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error creating user")
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID format")
 		return
 	}
 
-	// Map database user to response user
-	user := User{
-		ID:        dbUser.ID,
-		CreatedAt: dbUser.CreatedAt,
-		UpdatedAt: dbUser.UpdatedAt,
-		Email:     dbUser.Email,
+	err = cfg.db.UnlikeChirp(r.Context(), database.UnlikeChirpParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		cfg.logger.Error("error unliking chirp", "chirp_id", chirpID, "user_id", userID, "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error unliking chirp")
+		return
 	}
 
-	respondWithJSON(w, http.StatusCreated, user)
+	w.WriteHeader(http.StatusNoContent)
 }
 
-func (cfg *apiConfig) createChirpHandler(w http.ResponseWriter, r *http.Request) {
-	var req CreateChirpRequest
-	err := json.NewDecoder(r.Body).Decode(&req)
+func (cfg *apiConfig) updateChirpHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.userIDFromRequest(r)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		respondWithError(w, http.StatusUnauthorized, "Invalid or missing access token")
 		return
 	}
 
-	// Validate chirp length
-	if len(req.Body) > 140 {
+	chirpID, err := parseChirpID(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID format")
+		return
+	}
+
+	var req UpdateChirpRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(&req)
+	if err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	// Edits are held to the same rules as new chirps
+	if len(req.Body) > maxChirpLength {
 		respondWithError(w, http.StatusBadRequest, "Chirp is too long")
 		return
 	}
+	cleanedBody, isFlagged, err := cfg.moderateChirp(req.Body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Chirp contains prohibited language")
+		return
+	}
 
-	// Clean profanity
-	cleanedBody := cleanProfanity(req.Body)
+	// Check existence before ownership so we don't reveal who owns what
+	chirp, err := cfg.db.GetChirpByID(r.Context(), chirpID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusNotFound, "Chirp not found")
+			return
+		}
+		cfg.logger.Error("error getting chirp", "chirp_id", chirpID, "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting chirp")
+		return
+	}
+	if chirp.UserID != userID {
+		respondWithError(w, http.StatusForbidden, "You can only edit your own chirps")
+		return
+	}
 
-	// Create chirp in database
-	chirp, err := cfg.db.CreateChirp(r.Context(), database.CreateChirpParams{
-		ID:        uuid.New(),
-		CreatedAt: time.Now().UTC(),
-		UpdatedAt: time.Now().UTC(),
+	chirp, err = cfg.db.UpdateChirp(r.Context(), database.UpdateChirpParams{
+		ID:        chirpID,
 		Body:      cleanedBody,
-		UserID:    req.UserID,
+		IsFlagged: isFlagged,
+		UpdatedAt: time.Now().UTC(),
 	})
 	if err != nil {
-		log.Printf("Error creating chirp: %v", err)
-		respondWithError(w, http.StatusInternalServerError, "Error creating chirp")
+		if err == sql.ErrNoRows {
+			// Deleted between the lookup and the update
+			respondWithError(w, http.StatusNotFound, "Chirp not found")
+			return
+		}
+		cfg.logger.Error("error updating chirp", "chirp_id", chirpID, "user_id", userID, "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error updating chirp")
 		return
 	}
 
 	// Convert database chirp to response type
-	response := Chirp{
-		ID:        chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		Body:      chirp.Body,
-		UserID:    chirp.UserID,
+	response := []Chirp{databaseChirpToChirp(chirp)}
+	err = cfg.hydrateQuotedChirps(r.Context(), response)
+	if err != nil {
+		cfg.logger.Error("error getting quoted chirp", "chirp_id", chirpID, "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error updating chirp")
+		return
 	}
 
-	respondWithJSON(w, http.StatusCreated, response)
+	respondWithJSON(w, http.StatusOK, response[0])
 }
 
-func (cfg *apiConfig) getChirpsHandler(w http.ResponseWriter, r *http.Request) {
-	// Get all chirps from database
-	chirps, err := cfg.db.GetChirps(r.Context())
+func (cfg *apiConfig) getChirpContextHandler(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := parseChirpID(r.PathValue("chirpID"))
 	if err != nil {
-		log.Printf("Error getting chirps: %v", err)
-		respondWithError(w, http.StatusInternalServerError, "Error getting chirps")
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID format")
 		return
 	}
 
-	// Convert database chirps to response type
-	response := make([]Chirp, len(chirps))
-	for i, dbChirp := range chirps {
-		response[i] = Chirp{
-			ID:        dbChirp.ID,
-			CreatedAt: dbChirp.CreatedAt,
-			UpdatedAt: dbChirp.UpdatedAt,
-			Body:      dbChirp.Body,
-			UserID:    dbChirp.UserID,
+	query := r.URL.Query()
+	before := defaultContextSize
+	if v := query.Get("before"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > maxContextSize {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("before must be between 0 and %d", maxContextSize))
+			return
 		}
+		before = n
 	}
-
-	respondWithJSON(w, http.StatusOK, response)
-}
-
-func (cfg *apiConfig) getChirpByIDHandler(w http.ResponseWriter, r *http.Request) {
-	// Get chirp ID from path parameter
-	chirpIDStr := r.PathValue("chirpID")
-
-	// Parse the UUID
-	chirpID, err := uuid.Parse(chirpIDStr)
-	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID format")
-		return
+	after := defaultContextSize
+	if v := query.Get("after"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > maxContextSize {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("after must be between 0 and %d", maxContextSize))
+			return
+		}
+		after = n
 	}
 
-	// Get chirp from database
+	// Get the target chirp
 	chirp, err := cfg.db.GetChirpByID(r.Context(), chirpID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			respondWithError(w, http.StatusNotFound, "Chirp not found")
 			return
 		}
-		log.Printf("Error getting chirp: %v", err)
+		cfg.logger.Error("error getting chirp", "chirp_id", chirpID, "err", err)
 		respondWithError(w, http.StatusInternalServerError, "Error getting chirp")
 		return
 	}
 
-	// Convert database chirp to response type
-	response := Chirp{
-		ID:        chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		Body:      chirp.Body,
-		UserID:    chirp.UserID,
+	// Surround it with the global timeline, or just its author's
+	var authorID uuid.NullUUID
+	switch query.Get("timeline") {
+	case "", "global":
+	case "author":
+		authorID = uuid.NullUUID{UUID: chirp.UserID, Valid: true}
+	default:
+		respondWithError(w, http.StatusBadRequest, "timeline must be either global or author")
+		return
+	}
+
+	beforeChirps, err := cfg.db.GetChirpsBefore(r.Context(), database.GetChirpsBeforeParams{
+		CreatedAt:  chirp.CreatedAt,
+		ID:         chirp.ID,
+		UserID:     authorID,
+		MaxResults: int32(before),
+	})
+	if err != nil {
+		cfg.logger.Error("error getting chirps before", "chirp_id", chirp.ID, "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting chirps")
+		return
+	}
+	afterChirps, err := cfg.db.GetChirpsAfter(r.Context(), database.GetChirpsAfterParams{
+		CreatedAt:  chirp.CreatedAt,
+		ID:         chirp.ID,
+		UserID:     authorID,
+		MaxResults: int32(after),
+	})
+	if err != nil {
+		cfg.logger.Error("error getting chirps after", "chirp_id", chirp.ID, "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error getting chirps")
+		return
+	}
+
+	// The before query runs newest-first; flip it back to chronological order
+	response := ChirpContext{
+		Before: make([]Chirp, len(beforeChirps)),
+		Chirp:  databaseChirpToChirp(chirp),
+		After:  make([]Chirp, len(afterChirps)),
+	}
+	for i, dbChirp := range beforeChirps {
+		response.Before[len(beforeChirps)-1-i] = databaseChirpToChirp(dbChirp)
+	}
+	for i, dbChirp := range afterChirps {
+		response.After[i] = databaseChirpToChirp(dbChirp)
 	}
 
 	respondWithJSON(w, http.StatusOK, response)
 }
 
+// durationFromEnv reads a positive duration such as "30s" from the named
+// variable, exiting on a malformed value
+func durationFromEnv(name string, fallback time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		log.Fatalf("%s must be a positive duration", name)
+	}
+	return d
+}
+
 func main() {
 	godotenv.Load()
 
@@ -268,42 +2300,344 @@ func main() {
 		log.Fatal("PLATFORM must be set")
 	}
 
+	// Structured logs for aggregation; dev keeps a human-readable format
+	var logLevel slog.Level
+	if v := os.Getenv("LOG_LEVEL"); v != "" {
+		if err := logLevel.UnmarshalText([]byte(v)); err != nil {
+			log.Fatal("LOG_LEVEL must be one of debug, info, warn, or error")
+		}
+	}
+	logOptions := &slog.HandlerOptions{Level: logLevel}
+	var logger *slog.Logger
+	if platform == "dev" {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, logOptions))
+	} else {
+		logger = slog.New(slog.NewJSONHandler(os.Stderr, logOptions))
+	}
+	slog.SetDefault(logger)
+
+	jwtSecret := os.Getenv("JWT_SECRET")
+	if jwtSecret == "" {
+		log.Fatal("JWT_SECRET must be set")
+	}
+
+	polkaKey := os.Getenv("POLKA_KEY")
+	if polkaKey == "" {
+		log.Fatal("POLKA_KEY must be set")
+	}
+
+	adminUser := os.Getenv("ADMIN_USER")
+	adminPass := os.Getenv("ADMIN_PASS")
+	if (adminUser == "" || adminPass == "") && platform != "dev" {
+		logger.Warn("ADMIN_USER and ADMIN_PASS are not both set; admin endpoints will refuse every request")
+	}
+
+	urlIDFormat := os.Getenv("URL_ID_FORMAT")
+	if urlIDFormat == "" {
+		urlIDFormat = "uuid"
+	}
+	if urlIDFormat != "uuid" && urlIDFormat != "base62" {
+		log.Fatal("URL_ID_FORMAT must be either uuid or base62")
+	}
+
+	bannedEmailDomains := parseBannedEmailDomains(os.Getenv("BANNED_EMAIL_DOMAINS"))
+
+	// Signup helpers allow probing for registered emails, so they are opt-in
+	signupHelpersEnabled := os.Getenv("SIGNUP_HELPERS_ENABLED") == "true"
+
+	// Signups are open unless explicitly turned off
+	signupsDisabled := os.Getenv("SIGNUPS_ENABLED") == "false"
+
+	maxChirpsPerUser := 0
+	if v := os.Getenv("MAX_CHIRPS_PER_USER"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			log.Fatal("MAX_CHIRPS_PER_USER must be a non-negative integer")
+		}
+		maxChirpsPerUser = n
+	}
+
+	uuidVersion := 4
+	switch os.Getenv("UUID_VERSION") {
+	case "", "4":
+	case "7":
+		uuidVersion = 7
+	default:
+		log.Fatal("UUID_VERSION must be either 4 or 7")
+	}
+
+	chirpSweepInterval := time.Minute
+	if v := os.Getenv("CHIRP_SWEEP_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			log.Fatal("CHIRP_SWEEP_INTERVAL must be a positive duration")
+		}
+		chirpSweepInterval = d
+	}
+
+	var minAccountAgeToPost time.Duration
+	if v := os.Getenv("MIN_ACCOUNT_AGE_TO_POST"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			log.Fatal("MIN_ACCOUNT_AGE_TO_POST must be a non-negative duration")
+		}
+		minAccountAgeToPost = d
+	}
+
+	profanity, err := loadProfanityList(
+		os.Getenv("PROFANITY_FILE"),
+		os.Getenv("PROFANITY_CONFIG"),
+		os.Getenv("PROFANE_WORDS"),
+		os.Getenv("PROFANITY_STRICT") == "true",
+	)
+	if err != nil {
+		log.Fatalf("Error loading profanity config: %s", err)
+	}
+
+	profanityMode := profanityMode(os.Getenv("PROFANITY_MODE"))
+	switch profanityMode {
+	case "":
+		profanityMode = profanityModeMask
+	case profanityModeMask, profanityModeReject, profanityModeFlag:
+	default:
+		log.Fatal("PROFANITY_MODE must be one of mask, reject, or flag")
+	}
+
+	profanityListURL := os.Getenv("PROFANITY_LIST_URL")
+	profanityRefreshInterval := time.Hour
+	if v := os.Getenv("PROFANITY_REFRESH_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			log.Fatal("PROFANITY_REFRESH_INTERVAL must be a positive duration")
+		}
+		profanityRefreshInterval = d
+	}
+
+	dbMaxAttempts := 3
+	if v := os.Getenv("DB_MAX_ATTEMPTS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			log.Fatal("DB_MAX_ATTEMPTS must be a positive integer")
+		}
+		dbMaxAttempts = n
+	}
+
+	maxConcurrentRequests := 0
+	if v := os.Getenv("MAX_CONCURRENT_REQUESTS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			log.Fatal("MAX_CONCURRENT_REQUESTS must be a non-negative integer")
+		}
+		maxConcurrentRequests = n
+	}
+
+	maxBodyBytes := int64(defaultMaxBodyBytes)
+	if v := os.Getenv("MAX_BODY_BYTES"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 1 {
+			log.Fatal("MAX_BODY_BYTES must be a positive integer")
+		}
+		maxBodyBytes = n
+	}
+
+	dbMaxOpen := defaultDBMaxOpen
+	if v := os.Getenv("DB_MAX_OPEN"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			log.Fatal("DB_MAX_OPEN must be a positive integer")
+		}
+		dbMaxOpen = n
+	}
+
+	dbMaxIdle := min(defaultDBMaxIdle, dbMaxOpen)
+	if v := os.Getenv("DB_MAX_IDLE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > dbMaxOpen {
+			log.Fatal("DB_MAX_IDLE must be a non-negative integer no larger than DB_MAX_OPEN")
+		}
+		dbMaxIdle = n
+	}
+
+	dbConnMaxLifetime := durationFromEnv("DB_CONN_MAX_LIFETIME", defaultDBConnMaxLifetime)
+
+	rateLimit := float64(defaultRateLimit)
+	if v := os.Getenv("RATE_LIMIT_RPS"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 {
+			log.Fatal("RATE_LIMIT_RPS must be a non-negative number")
+		}
+		rateLimit = f
+	}
+	rateBurst := defaultRateBurst
+	if v := os.Getenv("RATE_LIMIT_BURST"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			log.Fatal("RATE_LIMIT_BURST must be a positive integer")
+		}
+		rateBurst = n
+	}
+	// A zero rate turns rate limiting off
+	var rateLimiter *ipRateLimiter
+	if rateLimit > 0 {
+		rateLimiter = newIPRateLimiter(rate.Limit(rateLimit), rateBurst)
+	}
+
+	readHeaderTimeout := durationFromEnv("READ_HEADER_TIMEOUT", 5*time.Second)
+	readTimeout := durationFromEnv("READ_TIMEOUT", 15*time.Second)
+	writeTimeout := durationFromEnv("WRITE_TIMEOUT", 30*time.Second)
+	idleTimeout := durationFromEnv("IDLE_TIMEOUT", 120*time.Second)
+	// How long in-flight requests get to finish once shutdown starts
+	shutdownTimeout := durationFromEnv("SHUTDOWN_TIMEOUT", 5*time.Second)
+
+	port := "8080"
+	if v := os.Getenv("PORT"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 65535 {
+			log.Fatal("PORT must be a number between 1 and 65535")
+		}
+		port = strconv.Itoa(n)
+	}
+
 	dbConn, err := sql.Open("postgres", dbURL)
 	if err != nil {
 		log.Fatalf("Error opening database: %s", err)
 	}
+	dbConn.SetMaxOpenConns(dbMaxOpen)
+	dbConn.SetMaxIdleConns(dbMaxIdle)
+	dbConn.SetConnMaxLifetime(dbConnMaxLifetime)
+	logger.Info("database pool configured",
+		"max_open", dbMaxOpen,
+		"max_idle", dbMaxIdle,
+		"conn_max_lifetime", dbConnMaxLifetime,
+	)
 	dbQueries := database.New(dbConn)
 
+	// sql.Open doesn't connect, so wait for Postgres before going further
+	if err := waitForDB(context.Background(), dbConn); err != nil {
+		log.Fatalf("Error connecting to database: %s", err)
+	}
+
+	// Bring the schema up to date unless migrations are managed elsewhere
+	if os.Getenv("SKIP_MIGRATIONS") != "true" {
+		if err := runMigrations(dbConn); err != nil {
+			log.Fatalf("Error running migrations: %s", err)
+		}
+	}
+
+	// Optionally import demo or migration data before serving
+	if seedPath := os.Getenv("SEED_FILE"); seedPath != "" {
+		if err := importSeedFile(context.Background(), dbConn, dbQueries, seedPath); err != nil {
+			log.Fatalf("Error importing seed file: %s", err)
+		}
+	}
+
 	apiCfg := apiConfig{
-		fileserverHits: atomic.Int32{},
-		db:             dbQueries,
-		platform:       platform,
+		fileserverHits:        atomic.Int32{},
+		db:                    dbQueries,
+		dbConn:                dbConn,
+		logger:                logger,
+		platform:              platform,
+		jwtSecret:             jwtSecret,
+		polkaKey:              polkaKey,
+		adminUser:             adminUser,
+		adminPass:             adminPass,
+		urlIDFormat:           urlIDFormat,
+		bannedEmailDomains:    bannedEmailDomains,
+		maxChirpsPerUser:      maxChirpsPerUser,
+		uuidVersion:           uuidVersion,
+		minAccountAgeToPost:   minAccountAgeToPost,
+		profanity:             profanity,
+		profanityMode:         profanityMode,
+		dbMaxAttempts:         dbMaxAttempts,
+		maxConcurrentRequests: maxConcurrentRequests,
+		maxBodyBytes:          maxBodyBytes,
+		rateLimiter:           rateLimiter,
+		signupHelpersEnabled:  signupHelpersEnabled,
+		signupsDisabled:       signupsDisabled,
+		envelopeResponses:     os.Getenv("ENVELOPE") == "true",
+	}
+	apiCfg.readOnly.Store(os.Getenv("READ_ONLY") == "true")
+	apiCfg.metrics = newHTTPMetrics(prometheus.DefaultRegisterer, func() float64 {
+		return float64(apiCfg.fileserverHits.Load())
+	})
+
+	// Background jobs run until shutdown
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	go apiCfg.sweepExpiredChirps(bgCtx, chirpSweepInterval)
+	if rateLimiter != nil {
+		go apiCfg.sweepRateLimiters(bgCtx, time.Minute)
+	}
+
+	// Optionally keep the profanity list in sync with a central URL
+	if profanityListURL != "" {
+		client := &http.Client{Timeout: 10 * time.Second}
+		apiCfg.updateProfanityFromURL(bgCtx, client, profanityListURL, profanity)
+		go apiCfg.refreshProfanityList(bgCtx, client, profanityListURL, profanityRefreshInterval, profanity)
 	}
 
 	// Create a new ServeMux
 	mux := http.NewServeMux()
-	port := "8888"
 
-	// Readiness endpoint at /api/healthz - GET only
-	mux.HandleFunc("GET /api/healthz", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
-	})
+	// Liveness and readiness probes - GET only
+	mux.HandleFunc("GET /api/healthz", healthzHandler)
+	mux.HandleFunc("GET /api/readyz", apiCfg.readyzHandler)
+
+	// Client configuration and capabilities endpoints
+	mux.HandleFunc("GET /api/config", apiCfg.clientConfigHandler)
+	mux.HandleFunc("GET /api/capabilities", apiCfg.capabilitiesHandler)
+	mux.HandleFunc("GET /api/metrics", apiCfg.metricsHandler)
+	mux.Handle("GET /metrics", promhttp.Handler())
 
 	// Chirps endpoints
-	mux.HandleFunc("POST /api/chirps", apiCfg.createChirpHandler)
+	mux.Handle("POST /api/chirps", apiCfg.middlewareRateLimit(http.HandlerFunc(apiCfg.createChirpHandler)))
+	mux.HandleFunc("POST /api/chirps/exists", apiCfg.chirpsExistHandler)
 	mux.HandleFunc("GET /api/chirps", apiCfg.getChirpsHandler)
+	mux.HandleFunc("GET /api/chirps/recent", apiCfg.getRecentChirpsHandler)
+	mux.HandleFunc("GET /api/chirps/ids", apiCfg.getChirpIDsHandler)
 	mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.getChirpByIDHandler)
+	mux.HandleFunc("PUT /api/chirps/{chirpID}", apiCfg.updateChirpHandler)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.deleteChirpHandler)
+	mux.HandleFunc("GET /api/chirps/{chirpID}/context", apiCfg.getChirpContextHandler)
+	mux.HandleFunc("GET /api/chirps/{chirpID}/quotes", apiCfg.getChirpQuotesHandler)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/likes", apiCfg.likeChirpHandler)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/likes", apiCfg.unlikeChirpHandler)
 
 	// Admin metrics endpoint - GET only, returns HTML
-	mux.HandleFunc("GET /admin/metrics", apiCfg.adminMetricsHandler)
+	mux.Handle("GET /admin/metrics", apiCfg.middlewareAdminAuth(http.HandlerFunc(apiCfg.adminMetricsHandler)))
+
+	// Admin metrics reset endpoint - POST only, keeps all data
+	mux.Handle("POST /admin/metrics/reset", apiCfg.middlewareAdminAuth(http.HandlerFunc(apiCfg.adminMetricsResetHandler)))
 
 	// Admin reset endpoint - POST only
-	mux.HandleFunc("POST /admin/reset", apiCfg.adminResetHandler)
+	mux.Handle("POST /admin/reset", apiCfg.middlewareAdminAuth(http.HandlerFunc(apiCfg.adminResetHandler)))
 
-	// User creation endpoint
+	// Admin read-only toggle - POST only
+	mux.Handle("POST /admin/read-only", apiCfg.middlewareAdminAuth(http.HandlerFunc(apiCfg.adminReadOnlyHandler)))
+
+	// User creation and update endpoints
 	mux.HandleFunc("POST /api/users", apiCfg.createUserHandler)
+	mux.HandleFunc("PUT /api/users", apiCfg.updateUserHandler)
+
+	// Login and token endpoints
+	mux.Handle("POST /api/login", apiCfg.middlewareRateLimit(http.HandlerFunc(apiCfg.loginHandler)))
+	mux.HandleFunc("POST /api/refresh", apiCfg.refreshHandler)
+	mux.HandleFunc("POST /api/revoke", apiCfg.revokeHandler)
+	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.polkaWebhookHandler)
+
+	// Fetch a single user by ID; the password hash is never returned
+	mux.HandleFunc("GET /api/users/{userID}", apiCfg.getUserByIDHandler)
+
+	// Word statistics for a user's chirps
+	mux.HandleFunc("GET /api/users/{userID}/word-stats", apiCfg.getUserWordStatsHandler)
+
+	// Calendar of a user's chirps for one month
+	mux.HandleFunc("GET /api/users/{userID}/chirps/calendar", apiCfg.getUserChirpCalendarHandler)
+
+	// Email availability check for signup forms - only when enabled
+	if apiCfg.signupHelpersEnabled {
+		mux.HandleFunc("GET /api/users/availability", apiCfg.emailAvailabilityHandler)
+	}
 
 	// Serve static files from the "assets" directory at /assets/
 	assetsFS := http.FileServer(http.Dir("assets"))
@@ -317,31 +2651,48 @@ func main() {
 	// Create server
 	server := &http.Server{
 		Addr:    ":" + port,
-		Handler: mux,
+		Handler: apiCfg.middlewareLogging(middlewareRequestID(middlewareGzip(apiCfg.middlewareEnvelope(apiCfg.middlewareConcurrencyLimit(apiCfg.middlewareReadOnly(apiCfg.middlewareMaxBodySize(mux))))))),
+		// Bound slow clients; defaults are 5s header, 15s read, 30s write, 120s idle
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
 	}
 
 	// Start the server in a goroutine
 	go func() {
-		log.Printf("Serving on port: %s", port)
+		logger.Info("serving", "port", port)
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("listen: %s\n", err)
 		}
 	}()
 
-	// Wait for interrupt signal to gracefully shutdown the server with
-	// a timeout of 5 seconds.
+	// Wait for interrupt signal to gracefully shutdown the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt)
 	<-quit
-	log.Println("Shutting down server...")
+	logger.Info("shutting down server", "timeout", shutdownTimeout)
 
-	// The context is used to inform the server it has 5 seconds to finish
-	// the request it is currently handling
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	// The context is used to inform the server how long it has to finish
+	// the requests it is currently handling
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 	defer cancel()
-	if err := server.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %s\n", err)
+	shutdownErr := server.Shutdown(ctx)
+	if errors.Is(shutdownErr, context.DeadlineExceeded) {
+		logger.Error("shutdown timed out", "in_flight", apiCfg.inFlight.Load())
+	} else if shutdownErr != nil {
+		logger.Error("error shutting down server", "err", shutdownErr)
+	}
+
+	// Handlers are done (or abandoned); stop the background jobs before
+	// releasing the pool they query, since os.Exit skips deferred calls
+	stopBackground()
+	if err := dbConn.Close(); err != nil {
+		logger.Error("error closing database", "err", err)
 	}
 
-	log.Println("Server exiting")
+	if shutdownErr != nil {
+		os.Exit(1)
+	}
+	logger.Info("server exiting")
 }
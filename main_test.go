@@ -0,0 +1,2254 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/troydot1x/chirpy_server/internal/database"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testLogger drops handler log output so test runs stay readable
+var testLogger = slog.New(slog.DiscardHandler)
+
+// newMockDB returns a sqlmock connection; unmet expectations fail the test
+func newMockDB(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet database expectations: %v", err)
+		}
+		db.Close()
+	})
+	return db, mock
+}
+
+// newMockQueries returns queries backed by sqlmock
+func newMockQueries(t *testing.T) (*database.Queries, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock := newMockDB(t)
+	return database.New(db), mock
+}
+
+var chirpColumns = []string{"id", "created_at", "updated_at", "body", "user_id", "expires_at", "is_flagged", "quoted_chirp_id"}
+
+// chirpRows builds result rows shaped like SELECT * FROM chirps
+func chirpRows(chirps ...database.Chirp) *sqlmock.Rows {
+	rows := sqlmock.NewRows(chirpColumns)
+	for _, c := range chirps {
+		rows.AddRow(c.ID, c.CreatedAt, c.UpdatedAt, c.Body, c.UserID, c.ExpiresAt, c.IsFlagged, c.QuotedChirpID)
+	}
+	return rows
+}
+
+// expectChirpListing expects the count and page queries behind GET /api/chirps
+func expectChirpListing(mock sqlmock.Sqlmock, chirps ...database.Chirp) {
+	mock.ExpectQuery("SELECT COUNT").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(len(chirps)))
+	if len(chirps) > 0 {
+		mock.ExpectQuery("LIMIT").WillReturnRows(chirpRows(chirps...))
+	}
+}
+
+var userColumns = []string{"id", "email", "created_at", "updated_at", "hashed_password", "is_chirpy_red"}
+
+// userRows builds result rows shaped like SELECT * FROM users
+func userRows(users ...database.User) *sqlmock.Rows {
+	rows := sqlmock.NewRows(userColumns)
+	for _, u := range users {
+		rows.AddRow(u.ID, u.Email, u.CreatedAt, u.UpdatedAt, u.HashedPassword, u.IsChirpyRed)
+	}
+	return rows
+}
+
+const testJWTSecret = "test-secret"
+
+// newChirpRequest builds a POST /api/chirps request authenticated as userID
+func newChirpRequest(t *testing.T, userID uuid.UUID, body string) *http.Request {
+	t.Helper()
+	token, err := makeJWT(userID, testJWTSecret, time.Hour)
+	if err != nil {
+		t.Fatalf("makeJWT: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/api/chirps", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+func TestBase62RoundTrip(t *testing.T) {
+	ids := []uuid.UUID{
+		uuid.Nil,
+		uuid.Max,
+		uuid.MustParse("00000000-0000-0000-0000-000000000001"),
+		uuid.MustParse("0f8fad5b-d9cb-469f-a165-70867728950e"),
+	}
+	for i := 0; i < 20; i++ {
+		ids = append(ids, uuid.New())
+	}
+
+	for _, id := range ids {
+		encoded := encodeBase62ID(id)
+		decoded, err := decodeBase62ID(encoded)
+		if err != nil {
+			t.Fatalf("decodeBase62ID(%q) for %s: %v", encoded, id, err)
+		}
+		if decoded != id {
+			t.Errorf("round trip of %s gave %s (encoded %q)", id, decoded, encoded)
+		}
+	}
+}
+
+func TestDecodeBase62IDRejectsInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"empty", ""},
+		{"bad character", "abc-def"},
+		{"negative", "-1"},
+		// 62^22 is larger than 2^128
+		{"too large", "10000000000000000000000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeBase62ID(tt.input); err == nil {
+				t.Errorf("decodeBase62ID(%q) succeeded, want error", tt.input)
+			}
+		})
+	}
+}
+
+func TestParseChirpIDAcceptsBothForms(t *testing.T) {
+	id := uuid.New()
+	for _, s := range []string{id.String(), encodeBase62ID(id)} {
+		got, err := parseChirpID(s)
+		if err != nil {
+			t.Fatalf("parseChirpID(%q): %v", s, err)
+		}
+		if got != id {
+			t.Errorf("parseChirpID(%q) = %s, want %s", s, got, id)
+		}
+	}
+}
+
+func TestChirpURL(t *testing.T) {
+	id := uuid.New()
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"uuid", "/api/chirps/" + id.String()},
+		{"base62", "/api/chirps/" + encodeBase62ID(id)},
+	}
+	for _, tt := range tests {
+		cfg := &apiConfig{logger: testLogger, urlIDFormat: tt.format}
+		if got := cfg.chirpURL(id); got != tt.want {
+			t.Errorf("chirpURL with %s format = %q, want %q", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestIsEmailDomainBanned(t *testing.T) {
+	cfg := &apiConfig{logger: testLogger, bannedEmailDomains: parseBannedEmailDomains(" Mailinator.com, *.temp-mail.org ,")}
+	tests := []struct {
+		email string
+		want  bool
+	}{
+		{"someone@mailinator.com", true},
+		{"someone@MAILINATOR.COM", true},
+		{"someone@example.com", false},
+		// Exact entries don't cover subdomains
+		{"someone@eu.mailinator.com", false},
+		{"someone@temp-mail.org", false},
+		{"someone@x.temp-mail.org", true},
+		{"someone@a.b.temp-mail.org", true},
+		{"no-at-sign", false},
+	}
+	for _, tt := range tests {
+		if got := cfg.isEmailDomainBanned(tt.email); got != tt.want {
+			t.Errorf("isEmailDomainBanned(%q) = %v, want %v", tt.email, got, tt.want)
+		}
+	}
+}
+
+func TestCreateUserBannedDomain(t *testing.T) {
+	db, mock := newMockQueries(t)
+	cfg := &apiConfig{db: db, logger: testLogger, bannedEmailDomains: parseBannedEmailDomains("mailinator.com,*.temp-mail.org")}
+
+	tests := []struct {
+		name       string
+		email      string
+		wantStatus int
+	}{
+		{"banned domain", "walt@mailinator.com", http.StatusBadRequest},
+		{"banned subdomain", "walt@x.temp-mail.org", http.StatusBadRequest},
+		{"allowed domain", "walt@example.com", http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantStatus == http.StatusCreated {
+				now := time.Now().UTC()
+				mock.ExpectQuery("INSERT INTO users").
+					WillReturnRows(userRows(database.User{ID: uuid.New(), Email: tt.email, CreatedAt: now, UpdatedAt: now}))
+			}
+
+			body := `{"email": "` + tt.email + `", "password": "04234"}`
+			req := httptest.NewRequest(http.MethodPost, "/api/users", strings.NewReader(body))
+			rec := httptest.NewRecorder()
+			cfg.createUserHandler(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus == http.StatusBadRequest && !strings.Contains(rec.Body.String(), "email domain not allowed") {
+				t.Errorf("body = %s, want the banned-domain error", rec.Body)
+			}
+		})
+	}
+}
+
+func TestGetChirpsExpandAuthor(t *testing.T) {
+	now := time.Now().UTC()
+	author := database.User{ID: uuid.New(), Email: "saul@bettercall.com", CreatedAt: now, UpdatedAt: now}
+	chirp := database.Chirp{ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Body: "hello", UserID: author.ID}
+
+	t.Run("expand=author embeds the author", func(t *testing.T) {
+		db, mock := newMockQueries(t)
+		cfg := &apiConfig{db: db, logger: testLogger}
+		mock.ExpectQuery("SELECT COUNT").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectQuery("JOIN users").WillReturnRows(
+			sqlmock.NewRows(append(chirpColumns, "author_email", "author_created_at", "author_updated_at", "author_is_chirpy_red")).
+				AddRow(chirp.ID, chirp.CreatedAt, chirp.UpdatedAt, chirp.Body, chirp.UserID, chirp.ExpiresAt, chirp.IsFlagged, chirp.QuotedChirpID, author.Email, author.CreatedAt, author.UpdatedAt, true))
+
+		rec := httptest.NewRecorder()
+		cfg.getChirpsHandler(rec, httptest.NewRequest(http.MethodGet, "/api/chirps?expand=author", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200 (body %s)", rec.Code, rec.Body)
+		}
+
+		var got []Chirp
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+		if len(got) != 1 || got[0].Author == nil {
+			t.Fatalf("got %+v, want one chirp with an author", got)
+		}
+		if got[0].Author.ID != author.ID || got[0].Author.Email != author.Email || !got[0].Author.IsChirpyRed {
+			t.Errorf("author = %+v, want id %s, email %s and Chirpy Red", got[0].Author, author.ID, author.Email)
+		}
+	})
+
+	t.Run("expand=author pages with limit and offset", func(t *testing.T) {
+		db, mock := newMockQueries(t)
+		cfg := &apiConfig{db: db, logger: testLogger}
+		mock.ExpectQuery("SELECT COUNT").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(12))
+		mock.ExpectQuery("JOIN users").WithArgs(nil, nil, true, 10, 5).WillReturnRows(
+			sqlmock.NewRows(append(chirpColumns, "author_email", "author_created_at", "author_updated_at", "author_is_chirpy_red")).
+				AddRow(chirp.ID, chirp.CreatedAt, chirp.UpdatedAt, chirp.Body, chirp.UserID, chirp.ExpiresAt, chirp.IsFlagged, chirp.QuotedChirpID, author.Email, author.CreatedAt, author.UpdatedAt, false))
+
+		rec := httptest.NewRecorder()
+		cfg.getChirpsHandler(rec, httptest.NewRequest(http.MethodGet, "/api/chirps?expand=author&sort=desc&limit=5&offset=10", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200 (body %s)", rec.Code, rec.Body)
+		}
+		if got := rec.Header().Get("X-Total-Count"); got != "12" {
+			t.Errorf("X-Total-Count = %q, want 12", got)
+		}
+	})
+
+	t.Run("expand=author past the end skips the query", func(t *testing.T) {
+		db, mock := newMockQueries(t)
+		cfg := &apiConfig{db: db, logger: testLogger}
+		mock.ExpectQuery("SELECT COUNT").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+		rec := httptest.NewRecorder()
+		cfg.getChirpsHandler(rec, httptest.NewRequest(http.MethodGet, "/api/chirps?expand=author&offset=3", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200 (body %s)", rec.Code, rec.Body)
+		}
+		if body := strings.TrimSpace(rec.Body.String()); body != "[]" {
+			t.Errorf("body = %s, want []", body)
+		}
+	})
+
+	t.Run("expand=author rejects a bad limit", func(t *testing.T) {
+		cfg := &apiConfig{logger: testLogger}
+		rec := httptest.NewRecorder()
+		cfg.getChirpsHandler(rec, httptest.NewRequest(http.MethodGet, "/api/chirps?expand=author&limit=500", nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want 400", rec.Code)
+		}
+	})
+
+	t.Run("default omits the author", func(t *testing.T) {
+		db, mock := newMockQueries(t)
+		cfg := &apiConfig{db: db, logger: testLogger}
+		expectChirpListing(mock, chirp)
+
+		rec := httptest.NewRecorder()
+		cfg.getChirpsHandler(rec, httptest.NewRequest(http.MethodGet, "/api/chirps", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200 (body %s)", rec.Code, rec.Body)
+		}
+
+		var got []map[string]any
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+		if len(got) != 1 {
+			t.Fatalf("got %d chirps, want 1", len(got))
+		}
+		if _, ok := got[0]["author"]; ok {
+			t.Errorf("default listing included an author: %v", got[0])
+		}
+	})
+
+	t.Run("unknown expand value", func(t *testing.T) {
+		cfg := &apiConfig{logger: testLogger}
+		rec := httptest.NewRecorder()
+		cfg.getChirpsHandler(rec, httptest.NewRequest(http.MethodGet, "/api/chirps?expand=likes", nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want 400", rec.Code)
+		}
+	})
+}
+
+func TestClientConfigHandler(t *testing.T) {
+	cfg := &apiConfig{logger: testLogger, platform: "dev", urlIDFormat: "base62"}
+	rec := httptest.NewRecorder()
+	cfg.clientConfigHandler(rec, httptest.NewRequest(http.MethodGet, "/api/config", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+
+	var got map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	want := map[string]any{
+		"max_chirp_length": float64(maxChirpLength),
+		"profanity_mask":   profanityMask,
+		"url_id_format":    "base62",
+		"signups_enabled":  true,
+		"page_size_max":    float64(maxChirpsLimit),
+	}
+	if len(got) != len(want) {
+		t.Errorf("got fields %v, want exactly %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %v, want %v", k, got[k], v)
+		}
+	}
+
+	// Only the allowlisted settings above may be exposed
+	for _, secret := range []string{"platform", "db_url", "jwt_secret", "polka_key"} {
+		if _, ok := got[secret]; ok {
+			t.Errorf("response exposes %q", secret)
+		}
+	}
+
+	cfg.signupsDisabled = true
+	rec = httptest.NewRecorder()
+	cfg.clientConfigHandler(rec, httptest.NewRequest(http.MethodGet, "/api/config", nil))
+	if !strings.Contains(rec.Body.String(), `"signups_enabled":false`) {
+		t.Errorf("body = %s, want signups_enabled false", rec.Body)
+	}
+}
+
+func TestCreateUserDuplicateEmail(t *testing.T) {
+	db, mock := newMockQueries(t)
+	cfg := &apiConfig{db: db, logger: testLogger}
+	now := time.Now().UTC()
+
+	// The second insert trips the unique constraint on users.email
+	mock.ExpectQuery("INSERT INTO users").
+		WillReturnRows(userRows(database.User{ID: uuid.New(), Email: "walt@example.com", CreatedAt: now, UpdatedAt: now}))
+	mock.ExpectQuery("INSERT INTO users").
+		WillReturnError(&pq.Error{Code: "23505", Constraint: "users_email_key"})
+
+	wantStatuses := []int{http.StatusCreated, http.StatusConflict}
+	for i, want := range wantStatuses {
+		body := `{"email": "walt@example.com", "password": "04234"}`
+		rec := httptest.NewRecorder()
+		cfg.createUserHandler(rec, httptest.NewRequest(http.MethodPost, "/api/users", strings.NewReader(body)))
+		if rec.Code != want {
+			t.Errorf("signup %d: status = %d, want %d (body %s)", i+1, rec.Code, want, rec.Body)
+		}
+	}
+}
+
+func TestMiddlewareMaxBodySize(t *testing.T) {
+	body := `{"email": "walt@example.com", "password": "` + strings.Repeat("a", 128) + `"}`
+
+	t.Run("plain error", func(t *testing.T) {
+		cfg := &apiConfig{logger: testLogger, maxBodyBytes: 64}
+		rec := httptest.NewRecorder()
+		cfg.middlewareMaxBodySize(http.HandlerFunc(cfg.createUserHandler)).
+			ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/users", strings.NewReader(body)))
+
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Fatalf("status = %d, want 413 (body %s)", rec.Code, rec.Body)
+		}
+		var got ErrorResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("decoding response: %v (body %s)", err, rec.Body)
+		}
+		if got.Error != "Request body too large" {
+			t.Errorf("error = %q, want \"Request body too large\"", got.Error)
+		}
+	})
+
+	t.Run("error envelope", func(t *testing.T) {
+		cfg := &apiConfig{logger: testLogger, maxBodyBytes: 64, envelopeResponses: true}
+		rec := httptest.NewRecorder()
+		cfg.middlewareEnvelope(cfg.middlewareMaxBodySize(http.HandlerFunc(cfg.createUserHandler))).
+			ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/users", strings.NewReader(body)))
+
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Fatalf("status = %d, want 413 (body %s)", rec.Code, rec.Body)
+		}
+		var got ErrorEnvelope
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("decoding response: %v (body %s)", err, rec.Body)
+		}
+		if got.Error != "Request body too large" || got.Meta.Timestamp.IsZero() {
+			t.Errorf("envelope = %+v, want the size error with meta", got)
+		}
+	})
+}
+
+func TestDecodeRejectsUnknownFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler func(cfg *apiConfig) http.HandlerFunc
+		body    string
+		field   string
+	}{
+		{"signup", func(cfg *apiConfig) http.HandlerFunc { return cfg.createUserHandler }, `{"email": "walt@example.com", "password": "04234", "nickname": "heisenberg"}`, "nickname"},
+		{"login", func(cfg *apiConfig) http.HandlerFunc { return cfg.loginHandler }, `{"email": "walt@example.com", "password": "04234", "remember_me": true}`, "remember_me"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &apiConfig{logger: testLogger}
+			rec := httptest.NewRecorder()
+			tt.handler(cfg)(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body)))
+
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400 (body %s)", rec.Code, rec.Body)
+			}
+			var got ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("decoding response: %v (body %s)", err, rec.Body)
+			}
+			if want := `Unknown field "` + tt.field + `"`; got.Error != want {
+				t.Errorf("error = %q, want %q", got.Error, want)
+			}
+		})
+	}
+}
+
+func TestCreateUserSignupsDisabled(t *testing.T) {
+	cfg := &apiConfig{logger: testLogger, signupsDisabled: true}
+	body := `{"email": "walt@example.com", "password": "04234"}`
+	rec := httptest.NewRecorder()
+	cfg.createUserHandler(rec, httptest.NewRequest(http.MethodPost, "/api/users", strings.NewReader(body)))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want 403", rec.Code)
+	}
+}
+
+func TestEmailAvailabilityHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		email      string
+		existing   []database.User
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "available",
+			email:      "new@example.com",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"available":true}`,
+		},
+		{
+			name:       "taken",
+			email:      "  taken@example.com ",
+			existing:   []database.User{{ID: uuid.New(), Email: "taken@example.com"}},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"available":false}`,
+		},
+		{
+			name:       "taken with different case",
+			email:      "Taken@Example.COM",
+			existing:   []database.User{{ID: uuid.New(), Email: "taken@example.com"}},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"available":false}`,
+		},
+		{name: "invalid", email: "not-an-email", wantStatus: http.StatusBadRequest},
+		{name: "display name", email: "Walt <walt@example.com>", wantStatus: http.StatusBadRequest},
+		{name: "missing", email: "", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockQueries(t)
+			cfg := &apiConfig{db: db, logger: testLogger}
+			if tt.wantStatus == http.StatusOK {
+				mock.ExpectQuery("FROM users").
+					WithArgs(strings.ToLower(strings.TrimSpace(tt.email))).
+					WillReturnRows(userRows(tt.existing...))
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/users/availability?email="+url.QueryEscape(tt.email), nil)
+			rec := httptest.NewRecorder()
+			cfg.emailAvailabilityHandler(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantBody != "" && strings.TrimSpace(rec.Body.String()) != tt.wantBody {
+				t.Errorf("body = %s, want %s", rec.Body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestCreateChirpLifetimeCap(t *testing.T) {
+	const chirpCap = 2
+	userID := uuid.New()
+
+	tests := []struct {
+		name       string
+		existing   int64
+		wantStatus int
+	}{
+		{"cap-th chirp succeeds", chirpCap - 1, http.StatusCreated},
+		{"chirp past the cap is rejected", chirpCap, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, mock := newMockDB(t)
+			cfg := &apiConfig{dbConn: conn, db: database.New(conn), logger: testLogger, jwtSecret: testJWTSecret, maxChirpsPerUser: chirpCap}
+
+			// The count and insert share a transaction holding the author's row lock
+			mock.ExpectBegin()
+			mock.ExpectQuery("FOR UPDATE").WithArgs(userID).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(userID))
+			mock.ExpectQuery("SELECT COUNT").
+				WithArgs(userID).
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(tt.existing))
+			if tt.wantStatus == http.StatusCreated {
+				now := time.Now().UTC()
+				mock.ExpectQuery("INSERT INTO chirps").WillReturnRows(chirpRows(database.Chirp{
+					ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Body: "hello", UserID: userID,
+				}))
+				mock.ExpectCommit()
+			} else {
+				mock.ExpectRollback()
+			}
+
+			body := `{"body": "hello"}`
+			rec := httptest.NewRecorder()
+			cfg.createChirpHandler(rec, newChirpRequest(t, userID, body))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus == http.StatusForbidden && !strings.Contains(rec.Body.String(), "chirp limit reached") {
+				t.Errorf("body = %s, want the chirp limit error", rec.Body)
+			}
+		})
+	}
+
+	t.Run("deleted author", func(t *testing.T) {
+		conn, mock := newMockDB(t)
+		cfg := &apiConfig{dbConn: conn, db: database.New(conn), logger: testLogger, jwtSecret: testJWTSecret, maxChirpsPerUser: chirpCap}
+		mock.ExpectBegin()
+		mock.ExpectQuery("FOR UPDATE").WithArgs(userID).WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
+
+		rec := httptest.NewRecorder()
+		cfg.createChirpHandler(rec, newChirpRequest(t, userID, `{"body": "hello"}`))
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("status = %d, want 401 (body %s)", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("deleted author without a cap", func(t *testing.T) {
+		db, mock := newMockQueries(t)
+		cfg := &apiConfig{db: db, logger: testLogger, jwtSecret: testJWTSecret}
+		mock.ExpectQuery("INSERT INTO chirps").WillReturnError(&pq.Error{Code: "23503", Constraint: "chirps_user_id_fkey"})
+
+		rec := httptest.NewRecorder()
+		cfg.createChirpHandler(rec, newChirpRequest(t, userID, `{"body": "hello"}`))
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("status = %d, want 401 (body %s)", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("no cap skips the transaction", func(t *testing.T) {
+		db, mock := newMockQueries(t)
+		cfg := &apiConfig{db: db, logger: testLogger, jwtSecret: testJWTSecret}
+		now := time.Now().UTC()
+		mock.ExpectQuery("INSERT INTO chirps").WillReturnRows(chirpRows(database.Chirp{
+			ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Body: "hello", UserID: userID,
+		}))
+
+		rec := httptest.NewRecorder()
+		cfg.createChirpHandler(rec, newChirpRequest(t, userID, `{"body": "hello"}`))
+		if rec.Code != http.StatusCreated {
+			t.Errorf("status = %d, want 201 (body %s)", rec.Code, rec.Body)
+		}
+	})
+}
+
+func TestGetChirpsHTML(t *testing.T) {
+	now := time.Now().UTC()
+	chirp := database.Chirp{ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Body: `<script>alert("hi")</script>`, UserID: uuid.New()}
+
+	tests := []struct {
+		name            string
+		target          string
+		accept          string
+		wantContentType string
+	}{
+		{"format query", "/api/chirps?format=html", "", "text/html"},
+		{"accept header", "/api/chirps", "text/html,application/xhtml+xml", "text/html"},
+		{"format=json beats the accept header", "/api/chirps?format=json", "text/html,application/xhtml+xml", "application/json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockQueries(t)
+			cfg := &apiConfig{db: db, logger: testLogger}
+			expectChirpListing(mock, chirp)
+
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			cfg.getChirpsHandler(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200 (body %s)", rec.Code, rec.Body)
+			}
+			if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, tt.wantContentType) {
+				t.Fatalf("Content-Type = %q, want %s", ct, tt.wantContentType)
+			}
+			if tt.wantContentType != "text/html" {
+				return
+			}
+			body := rec.Body.String()
+			if strings.Contains(body, "<script>") {
+				t.Errorf("chirp body was not escaped: %s", body)
+			}
+			if !strings.Contains(body, "&lt;script&gt;") {
+				t.Errorf("escaped chirp body missing from %s", body)
+			}
+		})
+	}
+
+	t.Run("pagination links", func(t *testing.T) {
+		db, mock := newMockQueries(t)
+		cfg := &apiConfig{db: db, logger: testLogger}
+		mock.ExpectQuery("SELECT COUNT").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(5))
+		mock.ExpectQuery("LIMIT").WillReturnRows(chirpRows(chirp))
+
+		rec := httptest.NewRecorder()
+		cfg.getChirpsHandler(rec, httptest.NewRequest(http.MethodGet, "/api/chirps?format=html&limit=1&offset=2", nil))
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200 (body %s)", rec.Code, rec.Body)
+		}
+		body := rec.Body.String()
+		for _, want := range []string{
+			`<a rel="prev" href="/api/chirps?format=html&amp;limit=1&amp;offset=1">`,
+			`<a rel="next" href="/api/chirps?format=html&amp;limit=1&amp;offset=3">`,
+		} {
+			if !strings.Contains(body, want) {
+				t.Errorf("body missing %s:\n%s", want, body)
+			}
+		}
+	})
+
+	t.Run("page-number links", func(t *testing.T) {
+		db, mock := newMockQueries(t)
+		cfg := &apiConfig{db: db, logger: testLogger}
+		mock.ExpectQuery("SELECT COUNT").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+		mock.ExpectQuery("LIMIT").WillReturnRows(chirpRows(chirp))
+
+		rec := httptest.NewRecorder()
+		cfg.getChirpsHandler(rec, httptest.NewRequest(http.MethodGet, "/api/chirps?format=html&page=2&per_page=1", nil))
+
+		if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+			t.Fatalf("Content-Type = %q, want text/html (body %s)", ct, rec.Body)
+		}
+		body := rec.Body.String()
+		for _, want := range []string{
+			`<a rel="prev" href="/api/chirps?format=html&amp;page=1&amp;per_page=1">`,
+			`<a rel="next" href="/api/chirps?format=html&amp;page=3&amp;per_page=1">`,
+		} {
+			if !strings.Contains(body, want) {
+				t.Errorf("body missing %s:\n%s", want, body)
+			}
+		}
+	})
+
+	t.Run("no links on a single page", func(t *testing.T) {
+		db, mock := newMockQueries(t)
+		cfg := &apiConfig{db: db, logger: testLogger}
+		expectChirpListing(mock, chirp)
+
+		rec := httptest.NewRecorder()
+		cfg.getChirpsHandler(rec, httptest.NewRequest(http.MethodGet, "/api/chirps?format=html", nil))
+		if strings.Contains(rec.Body.String(), "chirps-pagination") {
+			t.Errorf("unexpected pagination links in %s", rec.Body)
+		}
+	})
+
+	t.Run("json stays the default", func(t *testing.T) {
+		db, mock := newMockQueries(t)
+		cfg := &apiConfig{db: db, logger: testLogger}
+		expectChirpListing(mock, chirp)
+
+		rec := httptest.NewRecorder()
+		cfg.getChirpsHandler(rec, httptest.NewRequest(http.MethodGet, "/api/chirps", nil))
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q, want application/json", ct)
+		}
+	})
+}
+
+func TestGetRecentChirpsWindow(t *testing.T) {
+	fixedNow := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantSince  time.Time
+		wantStatus int
+	}{
+		{"default window", "", fixedNow.Add(-defaultRecentMinutes * time.Minute), http.StatusOK},
+		{"one minute", "?minutes=1", fixedNow.Add(-time.Minute), http.StatusOK},
+		{"maximum window", "?minutes=60", fixedNow.Add(-time.Hour), http.StatusOK},
+		{"zero", "?minutes=0", time.Time{}, http.StatusBadRequest},
+		{"over the cap", "?minutes=61", time.Time{}, http.StatusBadRequest},
+		{"not a number", "?minutes=five", time.Time{}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockQueries(t)
+			cfg := &apiConfig{db: db, logger: testLogger, clock: func() time.Time { return fixedNow }}
+
+			// Chirps at exactly the boundary are included; the query filters with >=
+			onBoundary := database.Chirp{ID: uuid.New(), CreatedAt: tt.wantSince, UpdatedAt: tt.wantSince, Body: "edge", UserID: uuid.New()}
+			newest := database.Chirp{ID: uuid.New(), CreatedAt: fixedNow, UpdatedAt: fixedNow, Body: "new", UserID: uuid.New()}
+			if tt.wantStatus == http.StatusOK {
+				mock.ExpectQuery("WHERE created_at >=").
+					WithArgs(tt.wantSince).
+					WillReturnRows(chirpRows(newest, onBoundary))
+			}
+
+			rec := httptest.NewRecorder()
+			cfg.getRecentChirpsHandler(rec, httptest.NewRequest(http.MethodGet, "/api/chirps/recent"+tt.query, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var got []Chirp
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if len(got) != 2 || got[0].ID != newest.ID || got[1].ID != onBoundary.ID {
+				t.Errorf("got %+v, want newest then boundary chirp", got)
+			}
+		})
+	}
+
+	t.Run("empty window is an empty array", func(t *testing.T) {
+		db, mock := newMockQueries(t)
+		cfg := &apiConfig{db: db, logger: testLogger, clock: func() time.Time { return fixedNow }}
+		mock.ExpectQuery("WHERE created_at >=").WillReturnRows(chirpRows())
+
+		rec := httptest.NewRecorder()
+		cfg.getRecentChirpsHandler(rec, httptest.NewRequest(http.MethodGet, "/api/chirps/recent", nil))
+		if got := strings.TrimSpace(rec.Body.String()); got != "[]" {
+			t.Errorf("body = %s, want []", got)
+		}
+	})
+}
+
+func TestNewIDVersion(t *testing.T) {
+	tests := []struct {
+		configured int
+		want       uuid.Version
+	}{
+		// Zero is what an unset UUID_VERSION leaves behind in tests
+		{0, 4},
+		{4, 4},
+		{7, 7},
+	}
+	for _, tt := range tests {
+		cfg := &apiConfig{logger: testLogger, uuidVersion: tt.configured}
+		for i := 0; i < 10; i++ {
+			if got := cfg.newID().Version(); got != tt.want {
+				t.Fatalf("uuidVersion %d generated a v%d ID, want v%d", tt.configured, got, tt.want)
+			}
+		}
+	}
+}
+
+// BenchmarkNewID reports how often a new ID sorts after the previous one.
+// B-tree inserts land at the end of the index in that case, so v7 should be
+// near 100% while v4 hovers around 50%.
+func BenchmarkNewID(b *testing.B) {
+	for _, version := range []int{4, 7} {
+		b.Run(fmt.Sprintf("v%d", version), func(b *testing.B) {
+			cfg := &apiConfig{logger: testLogger, uuidVersion: version}
+			prev := cfg.newID()
+			appended := 0
+			for i := 0; i < b.N; i++ {
+				id := cfg.newID()
+				if bytes.Compare(id[:], prev[:]) > 0 {
+					appended++
+				}
+				prev = id
+			}
+			b.ReportMetric(100*float64(appended)/float64(b.N), "%appended")
+		})
+	}
+}
+
+// Matches the filter every read query uses to hide expired chirps
+const notExpiredFilter = `expires_at IS NULL OR (chirps\.)?expires_at > NOW\(\)`
+
+func TestReadsExcludeExpiredChirps(t *testing.T) {
+	chirpID := uuid.New()
+	tests := []struct {
+		name    string
+		handler func(*apiConfig) http.HandlerFunc
+		target  string
+	}{
+		{"listing", func(cfg *apiConfig) http.HandlerFunc { return cfg.getChirpsHandler }, "/api/chirps"},
+		{"listing with authors", func(cfg *apiConfig) http.HandlerFunc { return cfg.getChirpsHandler }, "/api/chirps?expand=author"},
+		{"recent", func(cfg *apiConfig) http.HandlerFunc { return cfg.getRecentChirpsHandler }, "/api/chirps/recent"},
+		{"by id", func(cfg *apiConfig) http.HandlerFunc { return cfg.getChirpByIDHandler }, "/api/chirps/" + chirpID.String()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockQueries(t)
+			cfg := &apiConfig{db: db, logger: testLogger}
+			// An expired chirp is filtered by the database, so nothing comes back
+			mock.ExpectQuery(notExpiredFilter).WillReturnError(sql.ErrNoRows)
+
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req.SetPathValue("chirpID", chirpID.String())
+			rec := httptest.NewRecorder()
+			tt.handler(cfg)(rec, req)
+		})
+	}
+
+	t.Run("expired chirp by id is not found", func(t *testing.T) {
+		db, mock := newMockQueries(t)
+		cfg := &apiConfig{db: db, logger: testLogger}
+		mock.ExpectQuery(notExpiredFilter).WithArgs(chirpID).WillReturnRows(chirpRows())
+
+		req := httptest.NewRequest(http.MethodGet, "/api/chirps/"+chirpID.String(), nil)
+		req.SetPathValue("chirpID", chirpID.String())
+		rec := httptest.NewRecorder()
+		cfg.getChirpByIDHandler(rec, req)
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want 404", rec.Code)
+		}
+	})
+}
+
+func TestCreateChirpExpiresIn(t *testing.T) {
+	fixedNow := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	userID := uuid.New()
+
+	tests := []struct {
+		name          string
+		expiresIn     string
+		wantStatus    int
+		wantExpiresAt time.Time
+	}{
+		{"valid lifetime", "90m", http.StatusCreated, fixedNow.Add(90 * time.Minute)},
+		{"maximum lifetime", "168h", http.StatusCreated, fixedNow.Add(maxChirpLifetime)},
+		{"too long", "169h", http.StatusBadRequest, time.Time{}},
+		{"negative", "-5m", http.StatusBadRequest, time.Time{}},
+		{"not a duration", "soon", http.StatusBadRequest, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockQueries(t)
+			cfg := &apiConfig{db: db, logger: testLogger, jwtSecret: testJWTSecret, clock: func() time.Time { return fixedNow }}
+			if tt.wantStatus == http.StatusCreated {
+				expiresAt := sql.NullTime{Time: tt.wantExpiresAt, Valid: true}
+				mock.ExpectQuery("INSERT INTO chirps").
+					WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "short-lived", userID, tt.wantExpiresAt, false, nil).
+					WillReturnRows(chirpRows(database.Chirp{
+						ID: uuid.New(), CreatedAt: fixedNow, UpdatedAt: fixedNow, Body: "short-lived", UserID: userID, ExpiresAt: expiresAt,
+					}))
+			}
+
+			body := `{"body": "short-lived", "expires_in": "` + tt.expiresIn + `"}`
+			rec := httptest.NewRecorder()
+			cfg.createChirpHandler(rec, newChirpRequest(t, userID, body))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusCreated {
+				return
+			}
+
+			var got Chirp
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if got.ExpiresAt == nil || !got.ExpiresAt.Equal(tt.wantExpiresAt) {
+				t.Errorf("expires_at = %v, want %v", got.ExpiresAt, tt.wantExpiresAt)
+			}
+		})
+	}
+}
+
+func TestSweepExpiredChirps(t *testing.T) {
+	db, mock := newMockQueries(t)
+	cfg := &apiConfig{db: db, logger: testLogger}
+	mock.ExpectExec("DELETE FROM chirps").WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("DELETE FROM chirps").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		cfg.sweepExpiredChirps(ctx, 5*time.Millisecond)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for mock.ExpectationsWereMet() != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("sweeper did not delete expired chirps in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("sweeper did not stop after its context was canceled")
+	}
+}
+
+func TestCreateChirpMinAccountAge(t *testing.T) {
+	fixedNow := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	userID := uuid.New()
+
+	tests := []struct {
+		name       string
+		accountAge time.Duration
+		wantStatus int
+	}{
+		{"too new", time.Hour, http.StatusForbidden},
+		{"just old enough", 24 * time.Hour, http.StatusCreated},
+		{"old enough", 30 * 24 * time.Hour, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockQueries(t)
+			cfg := &apiConfig{db: db, logger: testLogger, jwtSecret: testJWTSecret, minAccountAgeToPost: 24 * time.Hour, clock: func() time.Time { return fixedNow }}
+
+			createdAt := fixedNow.Add(-tt.accountAge)
+			mock.ExpectQuery("FROM users").WithArgs(userID).
+				WillReturnRows(userRows(database.User{ID: userID, Email: "walt@example.com", CreatedAt: createdAt, UpdatedAt: createdAt}))
+			if tt.wantStatus == http.StatusCreated {
+				mock.ExpectQuery("INSERT INTO chirps").WillReturnRows(chirpRows(database.Chirp{
+					ID: uuid.New(), CreatedAt: fixedNow, UpdatedAt: fixedNow, Body: "hello", UserID: userID,
+				}))
+			}
+
+			body := `{"body": "hello"}`
+			rec := httptest.NewRecorder()
+			cfg.createChirpHandler(rec, newChirpRequest(t, userID, body))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus == http.StatusForbidden && !strings.Contains(rec.Body.String(), "account too new to post") {
+				t.Errorf("body = %s, want the account age error", rec.Body)
+			}
+		})
+	}
+}
+
+func TestCreateChirpSevereProfanity(t *testing.T) {
+	userID := uuid.New()
+	cfg := &apiConfig{logger: testLogger, jwtSecret: testJWTSecret, profanity: profanityList{"fornax": profanitySevere}}
+	body := `{"body": "you fornax"}`
+	rec := httptest.NewRecorder()
+	cfg.createChirpHandler(rec, newChirpRequest(t, userID, body))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400 (body %s)", rec.Code, rec.Body)
+	}
+}
+
+func TestMiddlewareLoggingInFlight(t *testing.T) {
+	cfg := &apiConfig{logger: testLogger}
+	handler := cfg.middlewareLogging(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := cfg.inFlight.Load(); got != 1 {
+			t.Errorf("in flight during the request = %d, want 1", got)
+		}
+		panic(http.ErrAbortHandler)
+	}))
+
+	func() {
+		defer func() { recover() }()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/chirps", nil))
+	}()
+	if got := cfg.inFlight.Load(); got != 0 {
+		t.Errorf("in flight after a panicking handler = %d, want 0", got)
+	}
+}
+
+func TestMiddlewareReadOnly(t *testing.T) {
+	reached := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name       string
+		readOnly   bool
+		method     string
+		path       string
+		wantStatus int
+	}{
+		{"read in read-only mode", true, http.MethodGet, "/api/chirps", http.StatusOK},
+		{"head in read-only mode", true, http.MethodHead, "/api/chirps", http.StatusOK},
+		{"create in read-only mode", true, http.MethodPost, "/api/chirps", http.StatusServiceUnavailable},
+		{"signup in read-only mode", true, http.MethodPost, "/api/users", http.StatusServiceUnavailable},
+		{"delete in read-only mode", true, http.MethodDelete, "/api/chirps/x", http.StatusServiceUnavailable},
+		{"admin in read-only mode", true, http.MethodPost, "/admin/read-only", http.StatusOK},
+		{"exists check in read-only mode", true, http.MethodPost, "/api/chirps/exists", http.StatusOK},
+		{"create when writable", false, http.MethodPost, "/api/chirps", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &apiConfig{logger: testLogger}
+			cfg.readOnly.Store(tt.readOnly)
+			reached = false
+
+			rec := httptest.NewRecorder()
+			cfg.middlewareReadOnly(next).ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if want := tt.wantStatus == http.StatusOK; reached != want {
+				t.Errorf("handler reached = %v, want %v", reached, want)
+			}
+			if tt.wantStatus == http.StatusServiceUnavailable && !strings.Contains(rec.Body.String(), "service is read-only") {
+				t.Errorf("body = %s, want the read-only error", rec.Body)
+			}
+		})
+	}
+}
+
+func TestAdminReadOnlyHandler(t *testing.T) {
+	t.Run("toggles", func(t *testing.T) {
+		cfg := &apiConfig{logger: testLogger, platform: "production"}
+		for _, want := range []bool{true, false} {
+			body := fmt.Sprintf(`{"read_only": %t}`, want)
+			rec := httptest.NewRecorder()
+			cfg.adminReadOnlyHandler(rec, httptest.NewRequest(http.MethodPost, "/admin/read-only", strings.NewReader(body)))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", rec.Code)
+			}
+			if cfg.readOnly.Load() != want {
+				t.Errorf("readOnly = %v, want %v", cfg.readOnly.Load(), want)
+			}
+		}
+	})
+
+	t.Run("requires admin credentials outside dev", func(t *testing.T) {
+		cfg := &apiConfig{logger: testLogger, platform: "production", adminUser: "admin", adminPass: "hunter2"}
+		handler := cfg.middlewareAdminAuth(http.HandlerFunc(cfg.adminReadOnlyHandler))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/read-only", strings.NewReader(`{"read_only": true}`)))
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("status = %d, want 401", rec.Code)
+		}
+		if cfg.readOnly.Load() {
+			t.Error("read-only mode was switched on without credentials")
+		}
+	})
+}
+
+func TestMiddlewareAdminAuth(t *testing.T) {
+	tests := []struct {
+		name       string
+		platform   string
+		adminUser  string
+		adminPass  string
+		basicUser  string
+		basicPass  string
+		wantStatus int
+	}{
+		{"valid credentials", "production", "admin", "hunter2", "admin", "hunter2", http.StatusOK},
+		{"wrong password", "production", "admin", "hunter2", "admin", "hunter3", http.StatusUnauthorized},
+		{"wrong user", "production", "admin", "hunter2", "root", "hunter2", http.StatusUnauthorized},
+		{"no credentials sent", "production", "admin", "hunter2", "", "", http.StatusUnauthorized},
+		{"unconfigured outside dev", "production", "", "", "", "", http.StatusUnauthorized},
+		{"unconfigured in dev", "dev", "", "", "", "", http.StatusOK},
+		{"configured in dev still checks", "dev", "admin", "hunter2", "", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &apiConfig{logger: testLogger, platform: tt.platform, adminUser: tt.adminUser, adminPass: tt.adminPass}
+			handler := cfg.middlewareAdminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/admin/reset", nil)
+			if tt.basicUser != "" {
+				req.SetBasicAuth(tt.basicUser, tt.basicPass)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("401 without a WWW-Authenticate challenge")
+			}
+		})
+	}
+}
+
+func TestGetChirpContextHandler(t *testing.T) {
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	authorID := uuid.New()
+	chirpAt := func(minutes int, body string) database.Chirp {
+		at := base.Add(time.Duration(minutes) * time.Minute)
+		return database.Chirp{ID: uuid.New(), CreatedAt: at, UpdatedAt: at, Body: body, UserID: authorID}
+	}
+	target := chirpAt(0, "target")
+	before1, before2 := chirpAt(-2, "before 1"), chirpAt(-1, "before 2")
+	after1, after2 := chirpAt(1, "after 1"), chirpAt(2, "after 2")
+
+	newRequest := func(query string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/api/chirps/"+target.ID.String()+"/context"+query, nil)
+		req.SetPathValue("chirpID", target.ID.String())
+		return req
+	}
+
+	t.Run("window around the chirp", func(t *testing.T) {
+		db, mock := newMockQueries(t)
+		cfg := &apiConfig{db: db, logger: testLogger}
+		mock.ExpectQuery("FROM chirps").WithArgs(target.ID).WillReturnRows(chirpRows(target))
+		// The before query comes back newest-first
+		mock.ExpectQuery(`\(created_at, id\) <`).
+			WithArgs(target.CreatedAt, target.ID, nil, 2).
+			WillReturnRows(chirpRows(before2, before1))
+		mock.ExpectQuery(`\(created_at, id\) >`).
+			WithArgs(target.CreatedAt, target.ID, nil, 2).
+			WillReturnRows(chirpRows(after1, after2))
+
+		rec := httptest.NewRecorder()
+		cfg.getChirpContextHandler(rec, newRequest("?before=2&after=2"))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200 (body %s)", rec.Code, rec.Body)
+		}
+
+		var got ChirpContext
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+		bodies := func(chirps []Chirp) []string {
+			var out []string
+			for _, c := range chirps {
+				out = append(out, c.Body)
+			}
+			return out
+		}
+		if b := bodies(got.Before); len(b) != 2 || b[0] != "before 1" || b[1] != "before 2" {
+			t.Errorf("before = %v, want chronological [before 1, before 2]", b)
+		}
+		if got.Chirp.ID != target.ID {
+			t.Errorf("chirp = %s, want %s", got.Chirp.ID, target.ID)
+		}
+		if a := bodies(got.After); len(a) != 2 || a[0] != "after 1" || a[1] != "after 2" {
+			t.Errorf("after = %v, want [after 1, after 2]", a)
+		}
+	})
+
+	t.Run("author timeline and empty sides", func(t *testing.T) {
+		db, mock := newMockQueries(t)
+		cfg := &apiConfig{db: db, logger: testLogger}
+		mock.ExpectQuery("FROM chirps").WithArgs(target.ID).WillReturnRows(chirpRows(target))
+		mock.ExpectQuery(`\(created_at, id\) <`).
+			WithArgs(target.CreatedAt, target.ID, authorID, 0).
+			WillReturnRows(chirpRows())
+		mock.ExpectQuery(`\(created_at, id\) >`).
+			WithArgs(target.CreatedAt, target.ID, authorID, 1).
+			WillReturnRows(chirpRows())
+
+		rec := httptest.NewRecorder()
+		cfg.getChirpContextHandler(rec, newRequest("?before=0&after=1&timeline=author"))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200 (body %s)", rec.Code, rec.Body)
+		}
+		if !strings.Contains(rec.Body.String(), `"before":[]`) || !strings.Contains(rec.Body.String(), `"after":[]`) {
+			t.Errorf("body = %s, want empty before and after arrays", rec.Body)
+		}
+	})
+
+	t.Run("missing chirp", func(t *testing.T) {
+		db, mock := newMockQueries(t)
+		cfg := &apiConfig{db: db, logger: testLogger}
+		mock.ExpectQuery("FROM chirps").WithArgs(target.ID).WillReturnRows(chirpRows())
+
+		rec := httptest.NewRecorder()
+		cfg.getChirpContextHandler(rec, newRequest(""))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want 404", rec.Code)
+		}
+	})
+
+	for _, query := range []string{"?before=21", "?after=-1", "?before=two"} {
+		t.Run("invalid "+query, func(t *testing.T) {
+			cfg := &apiConfig{logger: testLogger}
+			rec := httptest.NewRecorder()
+			cfg.getChirpContextHandler(rec, newRequest(query))
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", rec.Code)
+			}
+		})
+	}
+}
+
+func TestMiddlewareConcurrencyLimit(t *testing.T) {
+	const limit = 2
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	blocking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/chirps" {
+			entered <- struct{}{}
+			<-release
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	cfg := &apiConfig{logger: testLogger, maxConcurrentRequests: limit}
+	handler := cfg.middlewareConcurrencyLimit(blocking)
+	serve := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	// Saturate the limit with requests that wait for release
+	var wg sync.WaitGroup
+	for i := 0; i < limit; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if rec := serve("/api/chirps"); rec.Code != http.StatusOK {
+				t.Errorf("in-flight request status = %d, want 200", rec.Code)
+			}
+		}()
+		<-entered
+	}
+
+	rec := serve("/api/users")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status past the limit = %d, want 503", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("shed request has no Retry-After header")
+	}
+	if rec := serve("/api/healthz"); rec.Code != http.StatusOK {
+		t.Errorf("healthz status while saturated = %d, want 200", rec.Code)
+	}
+
+	close(release)
+	wg.Wait()
+	if rec := serve("/api/users"); rec.Code != http.StatusOK {
+		t.Errorf("status after draining = %d, want 200", rec.Code)
+	}
+}
+
+func TestMiddlewareConcurrencyLimitDisabled(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	cfg := &apiConfig{logger: testLogger}
+	if got := cfg.middlewareConcurrencyLimit(next); reflect.ValueOf(got).Pointer() != reflect.ValueOf(next).Pointer() {
+		t.Error("unset MAX_CONCURRENT_REQUESTS still wrapped the handler")
+	}
+}
+
+func TestChirpsExistHandler(t *testing.T) {
+	kept1, deleted, kept2 := uuid.New(), uuid.New(), uuid.New()
+
+	t.Run("mix of existing and deleted", func(t *testing.T) {
+		db, mock := newMockQueries(t)
+		cfg := &apiConfig{db: db, logger: testLogger}
+		mock.ExpectQuery("SELECT id FROM chirps").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(kept2).AddRow(kept1))
+
+		body := fmt.Sprintf(`{"ids": [%q, %q, %q]}`, kept1, deleted, kept2)
+		rec := httptest.NewRecorder()
+		cfg.chirpsExistHandler(rec, httptest.NewRequest(http.MethodPost, "/api/chirps/exists", strings.NewReader(body)))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200 (body %s)", rec.Code, rec.Body)
+		}
+
+		var got ChirpsExistResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+		// Both lists keep the order the IDs were requested in
+		if !reflect.DeepEqual(got.Existing, []uuid.UUID{kept1, kept2}) {
+			t.Errorf("existing = %v, want [%s %s]", got.Existing, kept1, kept2)
+		}
+		if !reflect.DeepEqual(got.Missing, []uuid.UUID{deleted}) {
+			t.Errorf("missing = %v, want [%s]", got.Missing, deleted)
+		}
+	})
+
+	t.Run("empty lists serialize as arrays", func(t *testing.T) {
+		db, mock := newMockQueries(t)
+		cfg := &apiConfig{db: db, logger: testLogger}
+		mock.ExpectQuery("SELECT id FROM chirps").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		rec := httptest.NewRecorder()
+		cfg.chirpsExistHandler(rec, httptest.NewRequest(http.MethodPost, "/api/chirps/exists", strings.NewReader(`{"ids": []}`)))
+		if got := strings.TrimSpace(rec.Body.String()); got != `{"existing":[],"missing":[]}` {
+			t.Errorf("body = %s, want empty arrays", got)
+		}
+	})
+
+	tooMany := make([]string, maxExistsIDs+1)
+	for i := range tooMany {
+		tooMany[i] = `"` + uuid.NewString() + `"`
+	}
+	for name, body := range map[string]string{
+		"malformed id": fmt.Sprintf(`{"ids": [%q, "not-a-uuid"]}`, kept1),
+		"too many ids": `{"ids": [` + strings.Join(tooMany, ",") + `]}`,
+		"bad json":     `{"ids": `,
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg := &apiConfig{logger: testLogger}
+			rec := httptest.NewRecorder()
+			cfg.chirpsExistHandler(rec, httptest.NewRequest(http.MethodPost, "/api/chirps/exists", strings.NewReader(body)))
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", rec.Code)
+			}
+		})
+	}
+}
+
+func TestCapabilitiesHandler(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  func() *apiConfig
+		want Capabilities
+	}{
+		{
+			name: "defaults",
+			cfg:  func() *apiConfig { return &apiConfig{logger: testLogger, urlIDFormat: "uuid"} },
+			want: Capabilities{PolkaWebhooks: true, Gzip: true, Likes: true},
+		},
+		{
+			name: "everything enabled",
+			cfg: func() *apiConfig {
+				cfg := &apiConfig{
+					logger:                testLogger,
+					signupHelpersEnabled:  true,
+					bannedEmailDomains:    parseBannedEmailDomains("mailinator.com"),
+					maxChirpsPerUser:      10,
+					minAccountAgeToPost:   time.Hour,
+					urlIDFormat:           "base62",
+					maxConcurrentRequests: 100,
+					rateLimiter:           newIPRateLimiter(1, 5),
+					metrics:               newHTTPMetrics(prometheus.NewRegistry(), func() float64 { return 0 }),
+					adminUser:             "admin",
+					adminPass:             "hunter2",
+				}
+				cfg.readOnly.Store(true)
+				return cfg
+			},
+			want: Capabilities{
+				SignupHelpers:      true,
+				BannedEmailDomains: true,
+				ChirpLimit:         true,
+				AccountAgeGate:     true,
+				Base62IDs:          true,
+				ReadOnly:           true,
+				LoadShedding:       true,
+				PolkaWebhooks:      true,
+				RateLimiting:       true,
+				Gzip:               true,
+				PrometheusMetrics:  true,
+				AdminAuth:          true,
+				Likes:              true,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tt.cfg().capabilitiesHandler(rec, httptest.NewRequest(http.MethodGet, "/api/capabilities", nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", rec.Code)
+			}
+			var got Capabilities
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	// Toggling read-only at runtime shows up without a restart
+	cfg := &apiConfig{logger: testLogger}
+	cfg.readOnly.Store(true)
+	rec := httptest.NewRecorder()
+	cfg.capabilitiesHandler(rec, httptest.NewRequest(http.MethodGet, "/api/capabilities", nil))
+	if !strings.Contains(rec.Body.String(), `"read_only":true`) {
+		t.Errorf("body = %s, want read_only true", rec.Body)
+	}
+}
+
+func TestCreateChirpProfanityModes(t *testing.T) {
+	list := profanityList{"kerfuffle": profanityMild, "fornax": profanitySevere}
+	userID := uuid.New()
+
+	tests := []struct {
+		mode        profanityMode
+		body        string
+		wantStatus  int
+		wantBody    string
+		wantFlagged bool
+	}{
+		{profanityModeMask, "what a kerfuffle", http.StatusCreated, "what a ****", false},
+		{profanityModeReject, "what a kerfuffle", http.StatusBadRequest, "", false},
+		{profanityModeFlag, "what a kerfuffle", http.StatusCreated, "what a kerfuffle", true},
+		{profanityModeFlag, "a clean chirp", http.StatusCreated, "a clean chirp", false},
+		// Severe words are rejected whatever the mode
+		{profanityModeMask, "you fornax", http.StatusBadRequest, "", false},
+		{profanityModeFlag, "you fornax", http.StatusBadRequest, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode)+"/"+tt.body, func(t *testing.T) {
+			db, mock := newMockQueries(t)
+			cfg := &apiConfig{db: db, logger: testLogger, jwtSecret: testJWTSecret, profanity: list, profanityMode: tt.mode}
+			if tt.wantStatus == http.StatusCreated {
+				now := time.Now().UTC()
+				mock.ExpectQuery("INSERT INTO chirps").
+					WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), tt.wantBody, userID, nil, tt.wantFlagged, nil).
+					WillReturnRows(chirpRows(database.Chirp{
+						ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Body: tt.wantBody, UserID: userID, IsFlagged: tt.wantFlagged,
+					}))
+			}
+
+			body := `{"body": "` + tt.body + `"}`
+			rec := httptest.NewRecorder()
+			cfg.createChirpHandler(rec, newChirpRequest(t, userID, body))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusCreated {
+				return
+			}
+
+			var got Chirp
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if got.Body != tt.wantBody || got.IsFlagged != tt.wantFlagged {
+				t.Errorf("got body %q flagged %v, want %q flagged %v", got.Body, got.IsFlagged, tt.wantBody, tt.wantFlagged)
+			}
+		})
+	}
+}
+
+func TestGetChirpsPage(t *testing.T) {
+	now := time.Now().UTC()
+	chirp := database.Chirp{ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Body: "hello", UserID: uuid.New()}
+
+	tests := []struct {
+		name           string
+		query          string
+		total          int64
+		wantDesc       bool
+		wantLimit      int
+		wantOffset     int
+		wantStatus     int
+		wantTotalPages int
+	}{
+		{"defaults", "page=1", 45, false, defaultPerPage, 0, http.StatusOK, 3},
+		{"second page", "page=2&per_page=10", 45, false, 10, 10, http.StatusOK, 5},
+		{"per_page alone", "per_page=50", 45, false, 50, 0, http.StatusOK, 1},
+		{"newest first", "page=1&sort=desc", 45, true, defaultPerPage, 0, http.StatusOK, 3},
+		{"past the end", "page=9&per_page=10", 45, false, 0, 0, http.StatusOK, 5},
+		{"page zero", "page=0", 0, false, 0, 0, http.StatusBadRequest, 0},
+		{"page not a number", "page=abc", 0, false, 0, 0, http.StatusBadRequest, 0},
+		{"per_page too large", "per_page=101", 0, false, 0, 0, http.StatusBadRequest, 0},
+		{"offset overflows int32", "page=30000000&per_page=100", 0, false, 0, 0, http.StatusBadRequest, 0},
+		{"unknown sort", "page=1&sort=up", 0, false, 0, 0, http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockQueries(t)
+			cfg := &apiConfig{db: db, logger: testLogger}
+			if tt.wantStatus == http.StatusOK {
+				mock.ExpectQuery("SELECT COUNT").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(tt.total))
+				if tt.wantLimit > 0 {
+					mock.ExpectQuery("LIMIT").
+						WithArgs(nil, nil, tt.wantDesc, tt.wantOffset, tt.wantLimit).
+						WillReturnRows(chirpRows(chirp))
+				}
+			}
+
+			rec := httptest.NewRecorder()
+			cfg.getChirpsHandler(rec, httptest.NewRequest(http.MethodGet, "/api/chirps?"+tt.query, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var got ChirpPage
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if got.Total != tt.total || got.TotalPages != tt.wantTotalPages {
+				t.Errorf("total = %d, total_pages = %d; want %d and %d", got.Total, got.TotalPages, tt.total, tt.wantTotalPages)
+			}
+			wantChirps := 1
+			if tt.wantLimit == 0 {
+				wantChirps = 0
+			}
+			if len(got.Chirps) != wantChirps {
+				t.Errorf("got %d chirps, want %d", len(got.Chirps), wantChirps)
+			}
+		})
+	}
+}
+
+func TestGetChirpsFiltersCombine(t *testing.T) {
+	now := time.Now().UTC()
+	authorID := uuid.New()
+	chirp := database.Chirp{ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Body: "hello", UserID: authorID}
+	filters := "author_id=" + authorID.String() + "&search=hello"
+
+	t.Run("with page", func(t *testing.T) {
+		db, mock := newMockQueries(t)
+		cfg := &apiConfig{db: db, logger: testLogger}
+		mock.ExpectQuery("SELECT COUNT").WithArgs(authorID, "hello").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectQuery("LIMIT").WithArgs(authorID, "hello", false, 0, defaultPerPage).
+			WillReturnRows(chirpRows(chirp))
+
+		rec := httptest.NewRecorder()
+		cfg.getChirpsHandler(rec, httptest.NewRequest(http.MethodGet, "/api/chirps?page=1&"+filters, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200 (body %s)", rec.Code, rec.Body)
+		}
+		var got ChirpPage
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+		if got.Total != 1 || len(got.Chirps) != 1 {
+			t.Errorf("got total %d with %d chirps, want 1 and 1", got.Total, len(got.Chirps))
+		}
+	})
+
+	t.Run("with expand=author", func(t *testing.T) {
+		db, mock := newMockQueries(t)
+		cfg := &apiConfig{db: db, logger: testLogger}
+		mock.ExpectQuery("SELECT COUNT").WithArgs(authorID, "hello").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectQuery("JOIN users").WithArgs(authorID, "hello", false, 0, defaultChirpsLimit).WillReturnRows(
+			sqlmock.NewRows(append(chirpColumns, "author_email", "author_created_at", "author_updated_at", "author_is_chirpy_red")).
+				AddRow(chirp.ID, chirp.CreatedAt, chirp.UpdatedAt, chirp.Body, chirp.UserID, chirp.ExpiresAt, chirp.IsFlagged, chirp.QuotedChirpID, "saul@bettercall.com", now, now, false))
+
+		rec := httptest.NewRecorder()
+		cfg.getChirpsHandler(rec, httptest.NewRequest(http.MethodGet, "/api/chirps?expand=author&"+filters, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200 (body %s)", rec.Code, rec.Body)
+		}
+		var got []Chirp
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+		if len(got) != 1 || got[0].Author == nil || got[0].Author.ID != authorID {
+			t.Errorf("got %+v, want one chirp by %s", got, authorID)
+		}
+	})
+}
+
+func TestGetChirpIDsHandler(t *testing.T) {
+	now := time.Now().UTC()
+	first, second := uuid.New(), uuid.New()
+	idRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "updated_at"}).AddRow(first, now).AddRow(second, now)
+	}
+
+	t.Run("bare IDs by default", func(t *testing.T) {
+		db, mock := newMockQueries(t)
+		cfg := &apiConfig{db: db, logger: testLogger}
+		mock.ExpectQuery("SELECT id, updated_at FROM chirps").WillReturnRows(idRows())
+
+		rec := httptest.NewRecorder()
+		cfg.getChirpIDsHandler(rec, httptest.NewRequest(http.MethodGet, "/api/chirps/ids", nil))
+		var got []uuid.UUID
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("decoding response: %v (body %s)", err, rec.Body)
+		}
+		if !reflect.DeepEqual(got, []uuid.UUID{first, second}) {
+			t.Errorf("ids = %v, want [%s %s]", got, first, second)
+		}
+	})
+
+	t.Run("include=updated_at returns pairs", func(t *testing.T) {
+		db, mock := newMockQueries(t)
+		cfg := &apiConfig{db: db, logger: testLogger}
+		mock.ExpectQuery("SELECT id, updated_at FROM chirps").WillReturnRows(idRows())
+
+		rec := httptest.NewRecorder()
+		cfg.getChirpIDsHandler(rec, httptest.NewRequest(http.MethodGet, "/api/chirps/ids?include=updated_at", nil))
+		var got []ChirpVersion
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("decoding response: %v (body %s)", err, rec.Body)
+		}
+		if len(got) != 2 || got[0].ID != first || !got[0].UpdatedAt.Equal(now) {
+			t.Errorf("versions = %+v, want %s first with updated_at %s", got, first, now)
+		}
+	})
+
+	t.Run("author_id narrows the IDs", func(t *testing.T) {
+		db, mock := newMockQueries(t)
+		cfg := &apiConfig{db: db, logger: testLogger}
+		authorID := uuid.New()
+		mock.ExpectQuery("SELECT id, updated_at FROM chirps").
+			WithArgs(authorID, false).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "updated_at"}).AddRow(first, now))
+
+		rec := httptest.NewRecorder()
+		cfg.getChirpIDsHandler(rec, httptest.NewRequest(http.MethodGet, "/api/chirps/ids?author_id="+authorID.String(), nil))
+		var got []uuid.UUID
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("decoding response: %v (body %s)", err, rec.Body)
+		}
+		if !reflect.DeepEqual(got, []uuid.UUID{first}) {
+			t.Errorf("ids = %v, want [%s]", got, first)
+		}
+	})
+
+	t.Run("invalid author_id", func(t *testing.T) {
+		cfg := &apiConfig{logger: testLogger}
+		rec := httptest.NewRecorder()
+		cfg.getChirpIDsHandler(rec, httptest.NewRequest(http.MethodGet, "/api/chirps/ids?author_id=nope", nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want 400", rec.Code)
+		}
+	})
+
+	t.Run("unknown include", func(t *testing.T) {
+		cfg := &apiConfig{logger: testLogger}
+		rec := httptest.NewRecorder()
+		cfg.getChirpIDsHandler(rec, httptest.NewRequest(http.MethodGet, "/api/chirps/ids?include=body", nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want 400", rec.Code)
+		}
+	})
+}
+
+func TestMiddlewareRequestID(t *testing.T) {
+	handler := middlewareRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respondWithJSON(w, http.StatusOK, struct{}{})
+	}))
+
+	callerID := uuid.NewString()
+	tests := []struct {
+		name   string
+		header string
+		reuse  bool
+	}{
+		{"no header", "", false},
+		{"caller UUID is reused", callerID, true},
+		{"non-UUID is replaced", "not-a-uuid", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/healthz", nil)
+			if tt.header != "" {
+				req.Header.Set(requestIDHeader, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			got := rec.Header().Get(requestIDHeader)
+			if _, err := uuid.Parse(got); err != nil {
+				t.Fatalf("%s = %q, want a UUID", requestIDHeader, got)
+			}
+			if (got == tt.header) != tt.reuse {
+				t.Errorf("%s = %q, caller sent %q, want reuse %v", requestIDHeader, got, tt.header, tt.reuse)
+			}
+		})
+	}
+}
+
+func TestEnvelopeResponses(t *testing.T) {
+	cfg := &apiConfig{logger: testLogger, envelopeResponses: true}
+
+	requestID := uuid.NewString()
+	handler := middlewareRequestID(cfg.middlewareEnvelope(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("fail") {
+			respondWithError(w, http.StatusBadRequest, "bad input")
+			return
+		}
+		respondWithJSON(w, http.StatusOK, map[string]string{"hello": "world"})
+	})))
+	serve := func(target string) map[string]json.RawMessage {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set(requestIDHeader, requestID)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		var got map[string]json.RawMessage
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("decoding response: %v (body %s)", err, rec.Body)
+		}
+		return got
+	}
+	checkMeta := func(raw json.RawMessage) {
+		var meta ResponseMeta
+		if err := json.Unmarshal(raw, &meta); err != nil {
+			t.Fatalf("decoding meta: %v", err)
+		}
+		if meta.RequestID != requestID || meta.Timestamp.IsZero() {
+			t.Errorf("meta = %+v, want request ID %s and a timestamp", meta, requestID)
+		}
+	}
+
+	got := serve("/")
+	if string(got["data"]) != `{"hello":"world"}` {
+		t.Errorf("data = %s, want the payload", got["data"])
+	}
+	checkMeta(got["meta"])
+
+	got = serve("/?fail")
+	if string(got["error"]) != `"bad input"` {
+		t.Errorf("error = %s, want \"bad input\"", got["error"])
+	}
+	if _, ok := got["data"]; ok {
+		t.Error("error envelope carries data")
+	}
+	checkMeta(got["meta"])
+
+	// Writers layered below the middleware still see the envelope
+	rec := httptest.NewRecorder()
+	respondWithJSON(&responseWriter{ResponseWriter: envelopeWriter{rec}}, http.StatusOK, "hi")
+	if !strings.HasPrefix(rec.Body.String(), `{"data":"hi"`) {
+		t.Errorf("wrapped writer body = %s, want an envelope", rec.Body)
+	}
+
+	disabled := &apiConfig{logger: testLogger}
+	rec = httptest.NewRecorder()
+	disabled.middlewareEnvelope(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respondWithJSON(w, http.StatusOK, "hi")
+	})).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if got := strings.TrimSpace(rec.Body.String()); got != `"hi"` {
+		t.Errorf("body with envelopes off = %s, want the bare payload", got)
+	}
+}
+
+func TestGetUserChirpCalendarHandler(t *testing.T) {
+	userID := uuid.New()
+	user := database.User{ID: userID, Email: "walt@example.com"}
+	day := func(d, hour int) time.Time { return time.Date(2025, time.March, d, hour, 0, 0, 0, time.UTC) }
+	newRequest := func(month string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/api/users/"+userID.String()+"/chirps/calendar?month="+month, nil)
+		req.SetPathValue("userID", userID.String())
+		return req
+	}
+
+	t.Run("groups chirps by day", func(t *testing.T) {
+		db, mock := newMockQueries(t)
+		cfg := &apiConfig{db: db, logger: testLogger}
+		mock.ExpectQuery("FROM users").WillReturnRows(userRows(user))
+		mock.ExpectQuery("created_at >= \\$2 AND created_at < \\$3").
+			WithArgs(userID, day(1, 0), time.Date(2025, time.April, 1, 0, 0, 0, 0, time.UTC)).
+			WillReturnRows(chirpRows(
+				database.Chirp{ID: uuid.New(), CreatedAt: day(3, 9), Body: "morning", UserID: userID},
+				database.Chirp{ID: uuid.New(), CreatedAt: day(3, 21), Body: "evening", UserID: userID},
+				database.Chirp{ID: uuid.New(), CreatedAt: day(17, 12), Body: "later", UserID: userID},
+			))
+
+		rec := httptest.NewRecorder()
+		cfg.getUserChirpCalendarHandler(rec, newRequest("2025-03"))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200 (body %s)", rec.Code, rec.Body)
+		}
+		var got map[string][]Chirp
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+		if len(got) != 2 || len(got["2025-03-03"]) != 2 || len(got["2025-03-17"]) != 1 {
+			t.Errorf("calendar = %v, want two chirps on 2025-03-03 and one on 2025-03-17", got)
+		}
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		db, mock := newMockQueries(t)
+		cfg := &apiConfig{db: db, logger: testLogger}
+		mock.ExpectQuery("FROM users").WillReturnError(sql.ErrNoRows)
+
+		rec := httptest.NewRecorder()
+		cfg.getUserChirpCalendarHandler(rec, newRequest("2025-03"))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want 404", rec.Code)
+		}
+	})
+
+	for _, month := range []string{"", "2025-3", "2025-13", "March"} {
+		t.Run("invalid month "+month, func(t *testing.T) {
+			cfg := &apiConfig{logger: testLogger}
+			rec := httptest.NewRecorder()
+			cfg.getUserChirpCalendarHandler(rec, newRequest(month))
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", rec.Code)
+			}
+		})
+	}
+}
+
+func TestDeleteChirpHandler(t *testing.T) {
+	now := time.Now().UTC()
+	ownerID := uuid.New()
+	chirp := database.Chirp{ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Body: "hello", UserID: ownerID}
+
+	tests := []struct {
+		name       string
+		callerID   uuid.UUID
+		exists     bool
+		wantStatus int
+	}{
+		// Even a non-owner sees 404 for a missing chirp, so ownership never leaks
+		{"missing chirp", uuid.New(), false, http.StatusNotFound},
+		{"someone else's chirp", uuid.New(), true, http.StatusForbidden},
+		{"owner deletes", ownerID, true, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockQueries(t)
+			cfg := &apiConfig{db: db, logger: testLogger, jwtSecret: testJWTSecret}
+			if tt.exists {
+				mock.ExpectQuery(`WHERE id = \$1`).WithArgs(chirp.ID).WillReturnRows(chirpRows(chirp))
+			} else {
+				mock.ExpectQuery(`WHERE id = \$1`).WithArgs(chirp.ID).WillReturnError(sql.ErrNoRows)
+			}
+			if tt.wantStatus == http.StatusNoContent {
+				mock.ExpectExec("DELETE FROM chirps").WithArgs(chirp.ID).WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+
+			req := newChirpRequest(t, tt.callerID, "")
+			req.Method = http.MethodDelete
+			req.SetPathValue("chirpID", chirp.ID.String())
+			rec := httptest.NewRecorder()
+			cfg.deleteChirpHandler(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+}
+
+func TestLikeChirpHandler(t *testing.T) {
+	now := time.Now().UTC()
+	userID := uuid.New()
+	chirp := database.Chirp{ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Body: "hello", UserID: uuid.New()}
+	newLikeRequest := func(method string) *http.Request {
+		req := newChirpRequest(t, userID, "")
+		req.Method = method
+		req.SetPathValue("chirpID", chirp.ID.String())
+		return req
+	}
+
+	t.Run("like", func(t *testing.T) {
+		db, mock := newMockQueries(t)
+		cfg := &apiConfig{db: db, logger: testLogger, jwtSecret: testJWTSecret}
+		mock.ExpectQuery(`WHERE id = \$1`).WithArgs(chirp.ID).WillReturnRows(chirpRows(chirp))
+		mock.ExpectExec("INSERT INTO chirp_likes").
+			WithArgs(userID, chirp.ID, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		rec := httptest.NewRecorder()
+		cfg.likeChirpHandler(rec, newLikeRequest(http.MethodPost))
+		if rec.Code != http.StatusNoContent {
+			t.Errorf("status = %d, want 204 (body %s)", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("like a missing chirp", func(t *testing.T) {
+		db, mock := newMockQueries(t)
+		cfg := &apiConfig{db: db, logger: testLogger, jwtSecret: testJWTSecret}
+		mock.ExpectQuery(`WHERE id = \$1`).WithArgs(chirp.ID).WillReturnError(sql.ErrNoRows)
+
+		rec := httptest.NewRecorder()
+		cfg.likeChirpHandler(rec, newLikeRequest(http.MethodPost))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want 404 (body %s)", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("unlike", func(t *testing.T) {
+		db, mock := newMockQueries(t)
+		cfg := &apiConfig{db: db, logger: testLogger, jwtSecret: testJWTSecret}
+		mock.ExpectExec("DELETE FROM chirp_likes").
+			WithArgs(userID, chirp.ID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		rec := httptest.NewRecorder()
+		cfg.unlikeChirpHandler(rec, newLikeRequest(http.MethodDelete))
+		if rec.Code != http.StatusNoContent {
+			t.Errorf("status = %d, want 204 (body %s)", rec.Code, rec.Body)
+		}
+	})
+}
+
+func TestLikeChirpForeignKeyViolation(t *testing.T) {
+	now := time.Now().UTC()
+	userID := uuid.New()
+	chirp := database.Chirp{ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Body: "hello", UserID: uuid.New()}
+
+	tests := []struct {
+		name       string
+		constraint string
+		wantStatus int
+	}{
+		{"chirp deleted meanwhile", "chirp_likes_chirp_id_fkey", http.StatusNotFound},
+		{"user deleted meanwhile", "chirp_likes_user_id_fkey", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockQueries(t)
+			cfg := &apiConfig{db: db, logger: testLogger, jwtSecret: testJWTSecret}
+			mock.ExpectQuery(`WHERE id = \$1`).WithArgs(chirp.ID).WillReturnRows(chirpRows(chirp))
+			mock.ExpectExec("INSERT INTO chirp_likes").
+				WillReturnError(&pq.Error{Code: "23503", Constraint: tt.constraint})
+
+			req := newChirpRequest(t, userID, "")
+			req.SetPathValue("chirpID", chirp.ID.String())
+			rec := httptest.NewRecorder()
+			cfg.likeChirpHandler(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+}
+
+func TestChirpQuotes(t *testing.T) {
+	now := time.Now().UTC()
+	userID := uuid.New()
+	original := database.Chirp{ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Body: "original", UserID: uuid.New()}
+	quote := database.Chirp{
+		ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Body: "so true", UserID: userID,
+		QuotedChirpID: uuid.NullUUID{UUID: original.ID, Valid: true},
+	}
+
+	t.Run("create embeds the quoted chirp", func(t *testing.T) {
+		db, mock := newMockQueries(t)
+		cfg := &apiConfig{db: db, logger: testLogger, jwtSecret: testJWTSecret}
+		mock.ExpectQuery(`WHERE id = \$1`).WithArgs(original.ID).WillReturnRows(chirpRows(original))
+		mock.ExpectQuery("INSERT INTO chirps").
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "so true", userID, nil, false, original.ID).
+			WillReturnRows(chirpRows(quote))
+		mock.ExpectQuery("ANY").WillReturnRows(chirpRows(original))
+
+		body := `{"body": "so true", "quoted_chirp_id": "` + original.ID.String() + `"}`
+		rec := httptest.NewRecorder()
+		cfg.createChirpHandler(rec, newChirpRequest(t, userID, body))
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("status = %d, want 201 (body %s)", rec.Code, rec.Body)
+		}
+		var got Chirp
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+		if got.QuotedChirp == nil || got.QuotedChirp.ID != original.ID || got.QuotedChirp.Body != "original" {
+			t.Errorf("quoted_chirp = %+v, want the original chirp", got.QuotedChirp)
+		}
+	})
+
+	t.Run("create rejects a missing quoted chirp", func(t *testing.T) {
+		db, mock := newMockQueries(t)
+		cfg := &apiConfig{db: db, logger: testLogger, jwtSecret: testJWTSecret}
+		mock.ExpectQuery(`WHERE id = \$1`).WillReturnError(sql.ErrNoRows)
+
+		body := `{"body": "so true", "quoted_chirp_id": "` + uuid.NewString() + `"}`
+		rec := httptest.NewRecorder()
+		cfg.createChirpHandler(rec, newChirpRequest(t, userID, body))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want 400", rec.Code)
+		}
+	})
+
+	t.Run("edit keeps the quoted chirp embedded", func(t *testing.T) {
+		db, mock := newMockQueries(t)
+		cfg := &apiConfig{db: db, logger: testLogger, jwtSecret: testJWTSecret}
+		edited := quote
+		edited.Body = "still true"
+		mock.ExpectQuery(`WHERE id = \$1`).WithArgs(quote.ID).WillReturnRows(chirpRows(quote))
+		mock.ExpectQuery("UPDATE chirps").
+			WithArgs(quote.ID, "still true", false, sqlmock.AnyArg()).
+			WillReturnRows(chirpRows(edited))
+		mock.ExpectQuery("ANY").WillReturnRows(chirpRows(original))
+
+		req := newChirpRequest(t, userID, `{"body": "still true"}`)
+		req.SetPathValue("chirpID", quote.ID.String())
+		rec := httptest.NewRecorder()
+		cfg.updateChirpHandler(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200 (body %s)", rec.Code, rec.Body)
+		}
+		var got Chirp
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+		if got.Body != "still true" || got.QuotedChirp == nil || got.QuotedChirp.ID != original.ID {
+			t.Errorf("got %+v, want the edited body with %s embedded", got, original.ID)
+		}
+	})
+
+	t.Run("edit rejects unknown fields", func(t *testing.T) {
+		cfg := &apiConfig{logger: testLogger, jwtSecret: testJWTSecret}
+		req := newChirpRequest(t, userID, `{"body": "still true", "quoted_chirp_id": "`+original.ID.String()+`"}`)
+		req.SetPathValue("chirpID", quote.ID.String())
+		rec := httptest.NewRecorder()
+		cfg.updateChirpHandler(rec, req)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "quoted_chirp_id") {
+			t.Errorf("status = %d (body %s), want 400 naming quoted_chirp_id", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("get by ID embeds the quoted chirp", func(t *testing.T) {
+		db, mock := newMockQueries(t)
+		cfg := &apiConfig{db: db, logger: testLogger}
+		mock.ExpectQuery(`WHERE id = \$1`).WithArgs(quote.ID).WillReturnRows(chirpRows(quote))
+		mock.ExpectQuery("ANY").WillReturnRows(chirpRows(original))
+
+		req := httptest.NewRequest(http.MethodGet, "/api/chirps/"+quote.ID.String(), nil)
+		req.SetPathValue("chirpID", quote.ID.String())
+		rec := httptest.NewRecorder()
+		cfg.getChirpByIDHandler(rec, req)
+		var got Chirp
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("decoding response: %v (body %s)", err, rec.Body)
+		}
+		if got.QuotedChirp == nil || got.QuotedChirp.ID != original.ID {
+			t.Errorf("quoted_chirp = %+v, want %s", got.QuotedChirp, original.ID)
+		}
+	})
+
+	t.Run("expand=quoted on listings", func(t *testing.T) {
+		db, mock := newMockQueries(t)
+		cfg := &apiConfig{db: db, logger: testLogger}
+		expectChirpListing(mock, original, quote)
+		mock.ExpectQuery("ANY").WillReturnRows(chirpRows(original))
+
+		rec := httptest.NewRecorder()
+		cfg.getChirpsHandler(rec, httptest.NewRequest(http.MethodGet, "/api/chirps?expand=quoted", nil))
+		var got []Chirp
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("decoding response: %v (body %s)", err, rec.Body)
+		}
+		if len(got) != 2 || got[0].QuotedChirp != nil || got[1].QuotedChirp == nil {
+			t.Errorf("got %+v, want only the quote to embed a chirp", got)
+		}
+	})
+
+	t.Run("quotes of a chirp", func(t *testing.T) {
+		db, mock := newMockQueries(t)
+		cfg := &apiConfig{db: db, logger: testLogger}
+		mock.ExpectQuery(`WHERE id = \$1`).WithArgs(original.ID).WillReturnRows(chirpRows(original))
+		mock.ExpectQuery("quoted_chirp_id = ").WithArgs(original.ID).WillReturnRows(chirpRows(quote))
+
+		req := httptest.NewRequest(http.MethodGet, "/api/chirps/"+original.ID.String()+"/quotes", nil)
+		req.SetPathValue("chirpID", original.ID.String())
+		rec := httptest.NewRecorder()
+		cfg.getChirpQuotesHandler(rec, req)
+		var got []Chirp
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("decoding response: %v (body %s)", err, rec.Body)
+		}
+		if len(got) != 1 || got[0].ID != quote.ID {
+			t.Errorf("quotes = %+v, want [%s]", got, quote.ID)
+		}
+	})
+
+	t.Run("quotes of a missing chirp", func(t *testing.T) {
+		db, mock := newMockQueries(t)
+		cfg := &apiConfig{db: db, logger: testLogger}
+		mock.ExpectQuery(`WHERE id = \$1`).WillReturnError(sql.ErrNoRows)
+
+		id := uuid.NewString()
+		req := httptest.NewRequest(http.MethodGet, "/api/chirps/"+id+"/quotes", nil)
+		req.SetPathValue("chirpID", id)
+		rec := httptest.NewRecorder()
+		cfg.getChirpQuotesHandler(rec, req)
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want 404", rec.Code)
+		}
+	})
+}
+
+func TestPolkaWebhookHandler(t *testing.T) {
+	userID := uuid.New()
+	tests := []struct {
+		name        string
+		apiKey      string
+		body        string
+		upgraded    int64
+		wantUpgrade bool
+		wantStatus  int
+	}{
+		{"upgrade", "ApiKey polka-key", `{"event": "user.upgraded", "data": {"user_id": "` + userID.String() + `"}}`, 1, true, http.StatusNoContent},
+		{"unknown user", "ApiKey polka-key", `{"event": "user.upgraded", "data": {"user_id": "` + userID.String() + `"}}`, 0, true, http.StatusNotFound},
+		{"other events are acknowledged", "ApiKey polka-key", `{"event": "user.payment_failed", "data": {"user_id": "` + userID.String() + `"}}`, 0, false, http.StatusNoContent},
+		{"malformed", "ApiKey polka-key", `{"event":`, 0, false, http.StatusBadRequest},
+		{"wrong key", "ApiKey other-key", `{"event": "user.upgraded", "data": {"user_id": "` + userID.String() + `"}}`, 0, false, http.StatusUnauthorized},
+		{"bearer instead of key", "Bearer polka-key", `{"event": "user.upgraded", "data": {"user_id": "` + userID.String() + `"}}`, 0, false, http.StatusUnauthorized},
+		{"no key", "", `{"event": "user.upgraded", "data": {"user_id": "` + userID.String() + `"}}`, 0, false, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockQueries(t)
+			cfg := &apiConfig{db: db, logger: testLogger, polkaKey: "polka-key"}
+			if tt.wantUpgrade {
+				mock.ExpectExec("UPDATE users").
+					WithArgs(userID, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, tt.upgraded))
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/api/polka/webhooks", strings.NewReader(tt.body))
+			if tt.apiKey != "" {
+				req.Header.Set("Authorization", tt.apiKey)
+			}
+			rec := httptest.NewRecorder()
+			cfg.polkaWebhookHandler(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+}
+
+func TestGetChirpsLimitOffset(t *testing.T) {
+	now := time.Now().UTC()
+	authorID := uuid.New()
+	chirp := database.Chirp{ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Body: "hello", UserID: authorID}
+
+	tests := []struct {
+		name       string
+		query      string
+		total      int64
+		wantAuthor any
+		wantSearch any
+		wantLimit  int
+		wantOffset int
+		wantStatus int
+	}{
+		{"defaults", "", 120, nil, nil, defaultChirpsLimit, 0, http.StatusOK},
+		{"limit and offset", "?limit=10&offset=30", 120, nil, nil, 10, 30, http.StatusOK},
+		{"author filter", "?author_id=" + authorID.String() + "&limit=5", 7, authorID, nil, 5, 0, http.StatusOK},
+		{"search", "?search=+Hello+", 3, nil, "Hello", defaultChirpsLimit, 0, http.StatusOK},
+		{"search escapes wildcards", "?search=100%25_off", 1, nil, `100\%\_off`, defaultChirpsLimit, 0, http.StatusOK},
+		{"blank search is ignored", "?search=++", 120, nil, nil, defaultChirpsLimit, 0, http.StatusOK},
+		{"offset past the end", "?offset=500", 120, nil, nil, 0, 0, http.StatusOK},
+		{"limit too large", "?limit=101", 0, nil, nil, 0, 0, http.StatusBadRequest},
+		{"negative offset", "?offset=-1", 0, nil, nil, 0, 0, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := newMockQueries(t)
+			cfg := &apiConfig{db: db, logger: testLogger}
+			if tt.wantStatus == http.StatusOK {
+				mock.ExpectQuery("SELECT COUNT").WithArgs(tt.wantAuthor, tt.wantSearch).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(tt.total))
+				if tt.wantLimit > 0 {
+					mock.ExpectQuery("LIMIT").
+						WithArgs(tt.wantAuthor, tt.wantSearch, false, tt.wantOffset, tt.wantLimit).
+						WillReturnRows(chirpRows(chirp))
+				}
+			}
+
+			rec := httptest.NewRecorder()
+			cfg.getChirpsHandler(rec, httptest.NewRequest(http.MethodGet, "/api/chirps"+tt.query, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if got := rec.Header().Get("X-Total-Count"); got != strconv.FormatInt(tt.total, 10) {
+				t.Errorf("X-Total-Count = %q, want %d", got, tt.total)
+			}
+		})
+	}
+}
+
+func TestLoginNormalizesEmail(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("04234"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hashing password: %v", err)
+	}
+	user := database.User{ID: uuid.New(), Email: "walt@example.com", HashedPassword: string(hash)}
+
+	db, mock := newMockQueries(t)
+	cfg := &apiConfig{db: db, logger: testLogger, jwtSecret: testJWTSecret}
+	mock.ExpectQuery("FROM users").WithArgs("walt@example.com").WillReturnRows(userRows(user))
+
+	// A wrong password stops before any tokens are issued
+	body := `{"email": " Walt@Example.com ", "password": "wrong"}`
+	rec := httptest.NewRecorder()
+	cfg.loginHandler(rec, httptest.NewRequest(http.MethodPost, "/api/login", strings.NewReader(body)))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want 401", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	cfg.loginHandler(rec, httptest.NewRequest(http.MethodPost, "/api/login", strings.NewReader(`{"email": "not-an-email", "password": "04234"}`)))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("malformed email: status = %d, want 401", rec.Code)
+	}
+}
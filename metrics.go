@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// httpMetrics holds the Prometheus collectors fed by middlewareLogging
+type httpMetrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+// newHTTPMetrics registers the request collectors with reg, along with a
+// gauge reading the fileserver hit counter. It's a gauge rather than a
+// counter because /admin/reset can set it back to zero.
+func newHTTPMetrics(reg prometheus.Registerer, fileserverHits func() float64) *httpMetrics {
+	m := &httpMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_http_requests_total",
+			Help: "HTTP requests handled, by method, route and status code.",
+		}, []string{"method", "path", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "chirpy_http_request_duration_seconds",
+			Help:    "HTTP request latency, by method and route.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "path"}),
+	}
+	reg.MustRegister(
+		m.requests,
+		m.duration,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "chirpy_fileserver_hits",
+			Help: "Requests served by the /app/ file server since the last reset.",
+		}, fileserverHits),
+	)
+	return m
+}
+
+func (m *httpMetrics) observe(r *http.Request, status int, elapsed time.Duration) {
+	path := routeLabel(r)
+	m.requests.WithLabelValues(r.Method, path, strconv.Itoa(status)).Inc()
+	m.duration.WithLabelValues(r.Method, path).Observe(elapsed.Seconds())
+}
+
+// routeLabel names the matched route pattern rather than the raw path, so
+// IDs in URLs don't create a new series per chirp or user
+func routeLabel(r *http.Request) string {
+	if r.Pattern == "" {
+		return "unmatched"
+	}
+	// Patterns may start with a method, e.g. "GET /api/chirps/{chirpID}"
+	if _, path, ok := strings.Cut(r.Pattern, " "); ok {
+		return path
+	}
+	return r.Pattern
+}
+
+// countEndpointHit bumps the counter for the route pattern r matched.
+// Requests that matched no route aren't counted.
+func (cfg *apiConfig) countEndpointHit(r *http.Request) {
+	if r.Pattern == "" {
+		return
+	}
+
+	cfg.endpointHitsMu.RLock()
+	counter, ok := cfg.endpointHits[r.Pattern]
+	cfg.endpointHitsMu.RUnlock()
+	if !ok {
+		cfg.endpointHitsMu.Lock()
+		if cfg.endpointHits == nil {
+			cfg.endpointHits = make(map[string]*atomic.Int64)
+		}
+		// Another request may have added it while we waited for the lock
+		counter, ok = cfg.endpointHits[r.Pattern]
+		if !ok {
+			counter = &atomic.Int64{}
+			cfg.endpointHits[r.Pattern] = counter
+		}
+		cfg.endpointHitsMu.Unlock()
+	}
+	counter.Add(1)
+}
+
+// endpointHitCounts snapshots the per-route counters
+func (cfg *apiConfig) endpointHitCounts() map[string]int64 {
+	cfg.endpointHitsMu.RLock()
+	defer cfg.endpointHitsMu.RUnlock()
+
+	counts := make(map[string]int64, len(cfg.endpointHits))
+	for pattern, counter := range cfg.endpointHits {
+		counts[pattern] = counter.Load()
+	}
+	return counts
+}
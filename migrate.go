@@ -0,0 +1,20 @@
+package main
+
+import (
+	"database/sql"
+	"embed"
+
+	"github.com/pressly/goose/v3"
+)
+
+//go:embed sql/schema/*.sql
+var embeddedMigrations embed.FS
+
+// runMigrations applies any pending migrations from sql/schema
+func runMigrations(db *sql.DB) error {
+	goose.SetBaseFS(embeddedMigrations)
+	if err := goose.SetDialect("postgres"); err != nil {
+		return err
+	}
+	return goose.Up(db, "sql/schema")
+}
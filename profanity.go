@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+)
+
+//go:embed profanity.json
+var defaultProfanityConfig []byte
+
+// Upper bound on a profanity list fetched from PROFANITY_LIST_URL
+const maxProfanityListSize = 1 << 20
+
+type profanitySeverity int
+
+const (
+	// Mild words are masked in place
+	profanityMild profanitySeverity = iota + 1
+	// Severe words cause the whole chirp to be rejected
+	profanitySevere
+)
+
+// profanityMode decides what happens to chirps containing mild words.
+// Severe words are rejected in every mode.
+type profanityMode string
+
+const (
+	profanityModeMask   profanityMode = "mask"
+	profanityModeReject profanityMode = "reject"
+	profanityModeFlag   profanityMode = "flag"
+)
+
+// profanityList maps lowercased words to their severity
+type profanityList map[string]profanitySeverity
+
+type profanityConfig struct {
+	Mild   []string `json:"mild"`
+	Severe []string `json:"severe"`
+}
+
+// loadProfanityList picks the profanity source: a file when path is set,
+// otherwise the raw JSON config, otherwise the comma-separated mild words,
+// otherwise the embedded defaults
+func loadProfanityList(path, raw, words string, strict bool) (profanityList, error) {
+	if path != "" {
+		return loadProfanityFile(path, strict)
+	}
+	if raw != "" {
+		return parseProfanityConfig([]byte(raw))
+	}
+	if mild := parseProfanityWordList(words); len(mild) > 0 {
+		list := make(profanityList, len(mild))
+		for _, word := range mild {
+			list[strings.ToLower(word)] = profanityMild
+		}
+		return list, nil
+	}
+	return parseProfanityConfig(defaultProfanityConfig)
+}
+
+// loadProfanityFile reads a JSON profanity config from path. Unless strict,
+// a missing or malformed file falls back to the embedded defaults.
+func loadProfanityFile(path string, strict bool) (profanityList, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		var list profanityList
+		list, err = parseProfanityConfig(data)
+		if err == nil {
+			return list, nil
+		}
+	}
+	if strict {
+		return nil, err
+	}
+
+	slog.Warn("could not load profanity file, using built-in list", "path", path, "err", err)
+	return parseProfanityConfig(defaultProfanityConfig)
+}
+
+func parseProfanityConfig(data []byte) (profanityList, error) {
+	var config profanityConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+
+	list := make(profanityList)
+	for _, word := range config.Mild {
+		list[strings.ToLower(word)] = profanityMild
+	}
+	// Severe wins when a word appears in both lists
+	for _, word := range config.Severe {
+		list[strings.ToLower(word)] = profanitySevere
+	}
+	return list, nil
+}
+
+// cleanProfanity masks mild words from the current list and returns the
+// most severe level found, or zero when the input is clean
+func (cfg *apiConfig) cleanProfanity(input string) (string, profanitySeverity) {
+	list := cfg.currentProfanity()
+	words := strings.Split(input, " ")
+	var worst profanitySeverity
+
+	for i, word := range words {
+		// Match the word without surrounding punctuation, but keep the
+		// punctuation in the output, e.g. "fornax!" becomes "****!"
+		start := len(word) - len(strings.TrimLeftFunc(word, unicode.IsPunct))
+		core := strings.TrimRightFunc(word[start:], unicode.IsPunct)
+		severity := list[strings.ToLower(core)]
+		if severity == profanityMild {
+			words[i] = word[:start] + profanityMask + word[start+len(core):]
+		}
+		worst = max(worst, severity)
+	}
+
+	return strings.Join(words, " "), worst
+}
+
+var errProhibitedLanguage = errors.New("chirp contains prohibited language")
+
+// moderateChirp applies the configured profanity mode to body, returning the
+// text to store and whether the chirp should be flagged. Chirps that must be
+// rejected outright return errProhibitedLanguage.
+func (cfg *apiConfig) moderateChirp(body string) (string, bool, error) {
+	cleaned, severity := cfg.cleanProfanity(body)
+	switch {
+	case severity == profanitySevere,
+		severity == profanityMild && cfg.profanityMode == profanityModeReject:
+		return "", false, errProhibitedLanguage
+	case severity == profanityMild && cfg.profanityMode == profanityModeFlag:
+		// Keep the original wording and let clients decide how to show it
+		return body, true, nil
+	}
+	return cleaned, false, nil
+}
+
+// parseProfanityWordList splits a newline- or comma-separated word list
+func parseProfanityWordList(text string) []string {
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return r == '\n' || r == ','
+	})
+	words := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if word := strings.TrimSpace(field); word != "" {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+func fetchProfanityWords(ctx context.Context, client *http.Client, url string) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxProfanityListSize))
+	if err != nil {
+		return nil, err
+	}
+
+	words := parseProfanityWordList(string(body))
+	if len(words) == 0 {
+		return nil, errors.New("profanity list is empty")
+	}
+	return words, nil
+}
+
+func (cfg *apiConfig) currentProfanity() profanityList {
+	cfg.profanityMu.RLock()
+	defer cfg.profanityMu.RUnlock()
+	return cfg.profanity
+}
+
+// updateProfanityFromURL swaps in the words fetched from url as mild words,
+// keeping severe words from base. On failure the current list stays.
+func (cfg *apiConfig) updateProfanityFromURL(ctx context.Context, client *http.Client, url string, base profanityList) {
+	words, err := fetchProfanityWords(ctx, client, url)
+	if err != nil {
+		cfg.logger.Error("error fetching profanity list, keeping current list", "url", url, "err", err)
+		return
+	}
+
+	list := make(profanityList, len(words))
+	for _, word := range words {
+		list[strings.ToLower(word)] = profanityMild
+	}
+	for word, severity := range base {
+		if severity == profanitySevere {
+			list[word] = profanitySevere
+		}
+	}
+
+	cfg.profanityMu.Lock()
+	cfg.profanity = list
+	cfg.profanityMu.Unlock()
+	cfg.logger.Info("loaded profanity list", "url", url, "count", len(words))
+}
+
+// refreshProfanityList re-fetches the list every interval until ctx is done
+func (cfg *apiConfig) refreshProfanityList(ctx context.Context, client *http.Client, url string, interval time.Duration, base profanityList) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cfg.updateProfanityFromURL(ctx, client, url, base)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCleanProfanitySeverity(t *testing.T) {
+	cfg := &apiConfig{logger: testLogger, profanity: profanityList{
+		"kerfuffle": profanityMild,
+		"sharbert":  profanityMild,
+		"fornax":    profanitySevere,
+	}}
+	tests := []struct {
+		name         string
+		input        string
+		want         string
+		wantSeverity profanitySeverity
+	}{
+		{"clean", "what a lovely day", "what a lovely day", 0},
+		{"mild is masked", "what a Kerfuffle this is", "what a **** this is", profanityMild},
+		{"several mild words", "kerfuffle and sharbert", "**** and ****", profanityMild},
+		{"severe", "you fornax", "you fornax", profanitySevere},
+		{"mixed content reports the worst", "kerfuffle and FORNAX", "**** and FORNAX", profanitySevere},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, severity := cfg.cleanProfanity(tt.input)
+			if severity != tt.wantSeverity {
+				t.Errorf("severity = %v, want %v", severity, tt.wantSeverity)
+			}
+			if got != tt.want {
+				t.Errorf("cleanProfanity(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCleanProfanityPunctuation(t *testing.T) {
+	cfg := &apiConfig{logger: testLogger, profanity: profanityList{
+		"kerfuffle": profanityMild,
+		"fornax":    profanitySevere,
+	}}
+	tests := []struct {
+		input        string
+		want         string
+		wantSeverity profanitySeverity
+	}{
+		{"what a kerfuffle, honestly", "what a ****, honestly", profanityMild},
+		{"such a kerfuffle.", "such a ****.", profanityMild},
+		{"Kerfuffle!", "****!", profanityMild},
+		{"kerfuffle?!", "****?!", profanityMild},
+		{`he said "kerfuffle" twice`, `he said "****" twice`, profanityMild},
+		{"'kerfuffle'", "'****'", profanityMild},
+		{"(kerfuffle)", "(****)", profanityMild},
+		{"kerfuffles", "kerfuffles", 0},
+		{"ker-fuffle", "ker-fuffle", 0},
+		{"you fornax!", "you fornax!", profanitySevere},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, severity := cfg.cleanProfanity(tt.input)
+			if got != tt.want || severity != tt.wantSeverity {
+				t.Errorf("cleanProfanity(%q) = %q, %v; want %q, %v", tt.input, got, severity, tt.want, tt.wantSeverity)
+			}
+		})
+	}
+}
+
+func TestLoadProfanityList(t *testing.T) {
+	t.Run("embedded defaults", func(t *testing.T) {
+		list, err := loadProfanityList("", "", "", false)
+		if err != nil {
+			t.Fatalf("loadProfanityList: %v", err)
+		}
+		if len(list) == 0 {
+			t.Error("embedded defaults produced an empty list")
+		}
+	})
+
+	t.Run("severe wins over mild", func(t *testing.T) {
+		list, err := loadProfanityList("", `{"mild": ["Heck", "darn"], "severe": ["HECK"]}`, "", false)
+		if err != nil {
+			t.Fatalf("loadProfanityList: %v", err)
+		}
+		if list["heck"] != profanitySevere {
+			t.Errorf("heck severity = %v, want severe", list["heck"])
+		}
+		if list["darn"] != profanityMild {
+			t.Errorf("darn severity = %v, want mild", list["darn"])
+		}
+	})
+
+	t.Run("malformed config", func(t *testing.T) {
+		if _, err := loadProfanityList("", `{"mild": `, "", false); err == nil {
+			t.Error("malformed config loaded without error")
+		}
+	})
+
+	t.Run("PROFANE_WORDS as mild words", func(t *testing.T) {
+		list, err := loadProfanityList("", "", "Blorp, snazzle", false)
+		if err != nil {
+			t.Fatalf("loadProfanityList: %v", err)
+		}
+		want := profanityList{"blorp": profanityMild, "snazzle": profanityMild}
+		if !reflect.DeepEqual(list, want) {
+			t.Errorf("list = %v, want %v", list, want)
+		}
+	})
+
+	t.Run("JSON config wins over PROFANE_WORDS", func(t *testing.T) {
+		list, err := loadProfanityList("", `{"mild": ["darn"]}`, "blorp", false)
+		if err != nil {
+			t.Fatalf("loadProfanityList: %v", err)
+		}
+		if _, ok := list["blorp"]; ok {
+			t.Errorf("list = %v, want PROFANE_WORDS ignored", list)
+		}
+	})
+}
+
+func TestLoadProfanityFile(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.json")
+	if err := os.WriteFile(valid, []byte(`{"mild": ["gosh"], "severe": ["blast"]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	malformed := filepath.Join(dir, "malformed.json")
+	if err := os.WriteFile(malformed, []byte(`{"mild": ["gosh"`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing.json")
+
+	defaults, err := parseProfanityConfig(defaultProfanityConfig)
+	if err != nil {
+		t.Fatalf("parsing embedded defaults: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		strict  bool
+		want    profanityList
+		wantErr bool
+	}{
+		{"valid file", valid, false, profanityList{"gosh": profanityMild, "blast": profanitySevere}, false},
+		{"missing file, lenient", missing, false, defaults, false},
+		{"missing file, strict", missing, true, nil, true},
+		{"malformed file, lenient", malformed, false, defaults, false},
+		{"malformed file, strict", malformed, true, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The file takes precedence over an inline config
+			got, err := loadProfanityList(tt.path, `{"mild": ["inline"]}`, "", tt.strict)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for word, severity := range tt.want {
+				if got[word] != severity {
+					t.Errorf("%s severity = %v, want %v", word, got[word], severity)
+				}
+			}
+		})
+	}
+}
+
+func TestParseProfanityWordList(t *testing.T) {
+	got := parseProfanityWordList("kerfuffle\n sharbert ,fornax,,\n\n")
+	want := []string{"kerfuffle", "sharbert", "fornax"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseProfanityWordList = %q, want %q", got, want)
+	}
+}
+
+func TestUpdateProfanityFromURL(t *testing.T) {
+	base := profanityList{"kerfuffle": profanityMild, "fornax": profanitySevere}
+
+	t.Run("fetched words replace mild words and keep severe ones", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("Blorp\nsnazzle"))
+		}))
+		defer srv.Close()
+
+		cfg := &apiConfig{logger: testLogger, profanity: base}
+		cfg.updateProfanityFromURL(context.Background(), srv.Client(), srv.URL, base)
+		want := profanityList{"blorp": profanityMild, "snazzle": profanityMild, "fornax": profanitySevere}
+		if got := cfg.currentProfanity(); !reflect.DeepEqual(got, want) {
+			t.Errorf("profanity = %v, want %v", got, want)
+		}
+	})
+
+	failures := map[string]http.HandlerFunc{
+		"server error": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		},
+		"empty list": func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(" \n,"))
+		},
+	}
+	for name, handler := range failures {
+		t.Run(name+" keeps the current list", func(t *testing.T) {
+			srv := httptest.NewServer(handler)
+			defer srv.Close()
+
+			cfg := &apiConfig{logger: testLogger, profanity: base}
+			cfg.updateProfanityFromURL(context.Background(), srv.Client(), srv.URL, base)
+			if got := cfg.currentProfanity(); !reflect.DeepEqual(got, base) {
+				t.Errorf("profanity = %v, want unchanged %v", got, base)
+			}
+		})
+	}
+}
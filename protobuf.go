@@ -0,0 +1,77 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/troydot1x/chirpy_server/internal/chirppb"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const protobufContentType = "application/x-protobuf"
+
+func wantsProtobuf(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), protobufContentType)
+}
+
+func chirpToProto(chirp Chirp) *chirppb.Chirp {
+	pb := &chirppb.Chirp{
+		Id:        chirp.ID.String(),
+		CreatedAt: timestamppb.New(chirp.CreatedAt),
+		UpdatedAt: timestamppb.New(chirp.UpdatedAt),
+		Body:      chirp.Body,
+		UserId:    chirp.UserID.String(),
+		IsFlagged: chirp.IsFlagged,
+	}
+	if chirp.ExpiresAt != nil {
+		pb.ExpiresAt = timestamppb.New(*chirp.ExpiresAt)
+	}
+	if chirp.Author != nil {
+		pb.Author = &chirppb.User{
+			Id:          chirp.Author.ID.String(),
+			CreatedAt:   timestamppb.New(chirp.Author.CreatedAt),
+			UpdatedAt:   timestamppb.New(chirp.Author.UpdatedAt),
+			Email:       chirp.Author.Email,
+			IsChirpyRed: chirp.Author.IsChirpyRed,
+		}
+	}
+	if chirp.QuotedChirpID != nil {
+		pb.QuotedChirpId = chirp.QuotedChirpID.String()
+	}
+	if chirp.QuotedChirp != nil {
+		pb.QuotedChirp = chirpToProto(*chirp.QuotedChirp)
+	}
+	return pb
+}
+
+func chirpsToProto(chirps []Chirp) *chirppb.ChirpList {
+	list := &chirppb.ChirpList{Chirps: make([]*chirppb.Chirp, len(chirps))}
+	for i, chirp := range chirps {
+		list.Chirps[i] = chirpToProto(chirp)
+	}
+	return list
+}
+
+// chirpPageToProto is chirpsToProto plus the page-number metadata
+func chirpPageToProto(page ChirpPage) *chirppb.ChirpList {
+	list := chirpsToProto(page.Chirps)
+	list.Page = int32(page.Page)
+	list.PerPage = int32(page.PerPage)
+	list.TotalPages = int32(page.TotalPages)
+	list.Total = page.Total
+	return list
+}
+
+func respondWithProtobuf(w http.ResponseWriter, code int, msg proto.Message) {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		slog.Error("error marshaling protobuf", "err", err)
+		respondWithError(w, http.StatusInternalServerError, "Error encoding response")
+		return
+	}
+	w.Header().Set("Content-Type", protobufContentType)
+	w.WriteHeader(code)
+	w.Write(data)
+}
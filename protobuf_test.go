@@ -0,0 +1,143 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/troydot1x/chirpy_server/internal/chirppb"
+	"github.com/troydot1x/chirpy_server/internal/database"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestChirpProtobufRoundTrip(t *testing.T) {
+	created := time.Date(2024, 3, 1, 12, 0, 0, 123000000, time.UTC)
+	expires := created.Add(time.Hour)
+	chirps := []Chirp{
+		{ID: uuid.New(), CreatedAt: created, UpdatedAt: created.Add(time.Minute), Body: "hello", UserID: uuid.New()},
+		{ID: uuid.New(), CreatedAt: created, UpdatedAt: created, Body: "short-lived", UserID: uuid.New(), ExpiresAt: &expires},
+	}
+
+	data, err := proto.Marshal(chirpsToProto(chirps))
+	if err != nil {
+		t.Fatalf("proto.Marshal: %v", err)
+	}
+	var decoded chirppb.ChirpList
+	if err := proto.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("proto.Unmarshal: %v", err)
+	}
+
+	if len(decoded.Chirps) != len(chirps) {
+		t.Fatalf("decoded %d chirps, want %d", len(decoded.Chirps), len(chirps))
+	}
+	for i, want := range chirps {
+		got := decoded.Chirps[i]
+		if got.Id != want.ID.String() || got.UserId != want.UserID.String() || got.Body != want.Body {
+			t.Errorf("chirp %d = %v, want %+v", i, got, want)
+		}
+		if !got.CreatedAt.AsTime().Equal(want.CreatedAt) || !got.UpdatedAt.AsTime().Equal(want.UpdatedAt) {
+			t.Errorf("chirp %d timestamps = %v/%v, want %v/%v", i, got.CreatedAt.AsTime(), got.UpdatedAt.AsTime(), want.CreatedAt, want.UpdatedAt)
+		}
+		if (want.ExpiresAt == nil) != (got.ExpiresAt == nil) {
+			t.Errorf("chirp %d expires_at = %v, want %v", i, got.ExpiresAt, want.ExpiresAt)
+		} else if want.ExpiresAt != nil && !got.ExpiresAt.AsTime().Equal(*want.ExpiresAt) {
+			t.Errorf("chirp %d expires_at = %v, want %v", i, got.ExpiresAt.AsTime(), *want.ExpiresAt)
+		}
+	}
+}
+
+func TestChirpProtobufExpansions(t *testing.T) {
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	quoted := Chirp{ID: uuid.New(), CreatedAt: created, UpdatedAt: created, Body: "original", UserID: uuid.New()}
+	author := User{ID: uuid.New(), CreatedAt: created, UpdatedAt: created, Email: "walt@example.com", IsChirpyRed: true}
+	chirp := Chirp{
+		ID:            uuid.New(),
+		CreatedAt:     created,
+		UpdatedAt:     created,
+		Body:          "quoting",
+		UserID:        author.ID,
+		Author:        &author,
+		QuotedChirpID: &quoted.ID,
+		QuotedChirp:   &quoted,
+	}
+
+	data, err := proto.Marshal(chirpToProto(chirp))
+	if err != nil {
+		t.Fatalf("proto.Marshal: %v", err)
+	}
+	var got chirppb.Chirp
+	if err := proto.Unmarshal(data, &got); err != nil {
+		t.Fatalf("proto.Unmarshal: %v", err)
+	}
+
+	if got.Author.GetId() != author.ID.String() || got.Author.GetEmail() != author.Email || !got.Author.GetIsChirpyRed() {
+		t.Errorf("author = %v, want %+v", got.Author, author)
+	}
+	if got.QuotedChirpId != quoted.ID.String() {
+		t.Errorf("quoted_chirp_id = %q, want %s", got.QuotedChirpId, quoted.ID)
+	}
+	if got.QuotedChirp.GetId() != quoted.ID.String() || got.QuotedChirp.GetBody() != quoted.Body {
+		t.Errorf("quoted_chirp = %v, want %+v", got.QuotedChirp, quoted)
+	}
+}
+
+func TestGetChirpByIDProtobuf(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	chirp := database.Chirp{ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Body: "hello", UserID: uuid.New()}
+
+	db, mock := newMockQueries(t)
+	cfg := &apiConfig{db: db, logger: testLogger}
+	mock.ExpectQuery("FROM chirps").WithArgs(chirp.ID).WillReturnRows(chirpRows(chirp))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/chirps/"+chirp.ID.String(), nil)
+	req.SetPathValue("chirpID", chirp.ID.String())
+	req.Header.Set("Accept", protobufContentType)
+	rec := httptest.NewRecorder()
+	cfg.getChirpByIDHandler(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (body %s)", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != protobufContentType {
+		t.Errorf("Content-Type = %q, want %q", ct, protobufContentType)
+	}
+	var got chirppb.Chirp
+	if err := proto.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("proto.Unmarshal: %v", err)
+	}
+	if got.Id != chirp.ID.String() || got.Body != chirp.Body {
+		t.Errorf("got %v, want chirp %s with body %q", &got, chirp.ID, chirp.Body)
+	}
+}
+
+func TestGetChirpsPageProtobuf(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	chirp := database.Chirp{ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Body: "hello", UserID: uuid.New()}
+
+	db, mock := newMockQueries(t)
+	cfg := &apiConfig{db: db, logger: testLogger}
+	mock.ExpectQuery("SELECT COUNT").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(11))
+	mock.ExpectQuery("LIMIT").WithArgs(nil, nil, false, 10, 10).WillReturnRows(chirpRows(chirp))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/chirps?page=2&per_page=10", nil)
+	req.Header.Set("Accept", protobufContentType)
+	rec := httptest.NewRecorder()
+	cfg.getChirpsHandler(rec, req)
+
+	if ct := rec.Header().Get("Content-Type"); ct != protobufContentType {
+		t.Fatalf("Content-Type = %q, want %q (body %s)", ct, protobufContentType, rec.Body)
+	}
+	var got chirppb.ChirpList
+	if err := proto.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("proto.Unmarshal: %v", err)
+	}
+	if len(got.Chirps) != 1 || got.Chirps[0].Id != chirp.ID.String() {
+		t.Errorf("chirps = %v, want [%s]", got.Chirps, chirp.ID)
+	}
+	if got.Page != 2 || got.PerPage != 10 || got.TotalPages != 2 || got.Total != 11 {
+		t.Errorf("page metadata = %d/%d/%d/%d, want 2/10/2/11", got.Page, got.PerPage, got.TotalPages, got.Total)
+	}
+}
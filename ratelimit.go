@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	defaultRateLimit = 10
+	defaultRateBurst = 20
+	// Limiters idle this long are dropped; a returning client starts with a full bucket
+	rateLimiterIdleTTL = 3 * time.Minute
+)
+
+type ipLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// ipRateLimiter hands out a token bucket per client IP
+type ipRateLimiter struct {
+	mu       sync.Mutex
+	limiters map[string]*ipLimiter
+	limit    rate.Limit
+	burst    int
+}
+
+func newIPRateLimiter(limit rate.Limit, burst int) *ipRateLimiter {
+	return &ipRateLimiter{
+		limiters: make(map[string]*ipLimiter),
+		limit:    limit,
+		burst:    burst,
+	}
+}
+
+func (l *ipRateLimiter) get(ip string) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.limiters[ip]
+	if !ok {
+		entry = &ipLimiter{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.limiters[ip] = entry
+	}
+	entry.lastSeen = time.Now()
+	return entry.limiter
+}
+
+// cleanup drops limiters not used since cutoff
+func (l *ipRateLimiter) cleanup(cutoff time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for ip, entry := range l.limiters {
+		if entry.lastSeen.Before(cutoff) {
+			delete(l.limiters, ip)
+		}
+	}
+}
+
+// clientIP returns the host part of the connection's remote address
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// middlewareRateLimit answers 429 once a client IP runs out of tokens
+func (cfg *apiConfig) middlewareRateLimit(next http.Handler) http.Handler {
+	if cfg.rateLimiter == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reservation := cfg.rateLimiter.get(clientIP(r)).Reserve()
+		if delay := reservation.Delay(); delay > 0 {
+			// Give the token back; this request is refused, not queued
+			reservation.Cancel()
+			retryAfter := int(math.Ceil(delay.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			respondWithError(w, http.StatusTooManyRequests, "Too many requests")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// sweepRateLimiters forgets idle client IPs every interval until ctx is done
+func (cfg *apiConfig) sweepRateLimiters(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cfg.rateLimiter.cleanup(time.Now().Add(-rateLimiterIdleTTL))
+		}
+	}
+}
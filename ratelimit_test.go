@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestMiddlewareRateLimit(t *testing.T) {
+	const burst = 3
+	// A token every minute, so nothing refills while the test runs
+	cfg := &apiConfig{logger: testLogger, rateLimiter: newIPRateLimiter(rate.Every(time.Minute), burst)}
+	handler := cfg.middlewareRateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	serve := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/login", nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := range burst {
+		if rec := serve("192.0.2.1:1234"); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d, want 200", i+1, rec.Code)
+		}
+	}
+
+	rec := serve("192.0.2.1:5678")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("request past the burst: status = %d, want 429", rec.Code)
+	}
+	retryAfter, err := strconv.Atoi(rec.Header().Get("Retry-After"))
+	if err != nil || retryAfter < 1 || retryAfter > 60 {
+		t.Errorf("Retry-After = %q, want between 1 and 60 seconds", rec.Header().Get("Retry-After"))
+	}
+
+	// Buckets are per IP, not per connection
+	if rec := serve("198.51.100.7:1234"); rec.Code != http.StatusOK {
+		t.Errorf("other client: status = %d, want 200", rec.Code)
+	}
+}
+
+func TestSweepRateLimiters(t *testing.T) {
+	limiter := newIPRateLimiter(rate.Limit(1), 1)
+	cfg := &apiConfig{logger: testLogger, rateLimiter: limiter}
+	limiter.get("192.0.2.1")
+	limiter.get("198.51.100.7")
+
+	// Only the first client has been idle past the TTL
+	limiter.mu.Lock()
+	limiter.limiters["192.0.2.1"].lastSeen = time.Now().Add(-rateLimiterIdleTTL - time.Minute)
+	limiter.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		cfg.sweepRateLimiters(ctx, time.Millisecond)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		limiter.mu.Lock()
+		_, idle := limiter.limiters["192.0.2.1"]
+		_, active := limiter.limiters["198.51.100.7"]
+		limiter.mu.Unlock()
+
+		if !idle {
+			if !active {
+				t.Error("sweep evicted a client that was still active")
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("idle client was never evicted")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
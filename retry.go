@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const dbRetryBackoff = 50 * time.Millisecond
+
+// Postgres error codes that are worth retrying as-is
+var transientDBErrorCodes = map[pq.ErrorCode]bool{
+	"40001": true, // serialization_failure
+	"40P01": true, // deadlock_detected
+}
+
+func isTransientDBError(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && transientDBErrorCodes[pqErr.Code]
+}
+
+// withDBRetry runs op, retrying transient Postgres errors with exponential
+// backoff up to cfg.dbMaxAttempts attempts. Other errors return immediately.
+func (cfg *apiConfig) withDBRetry(ctx context.Context, op func() error) error {
+	backoff := dbRetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := op()
+		if err == nil || !isTransientDBError(err) || attempt >= cfg.dbMaxAttempts {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
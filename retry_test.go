@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/troydot1x/chirpy_server/internal/database"
+)
+
+func TestWithDBRetry(t *testing.T) {
+	serialization := &pq.Error{Code: "40001"}
+	deadlock := fmt.Errorf("creating chirp: %w", &pq.Error{Code: "40P01"})
+	uniqueViolation := &pq.Error{Code: "23505"}
+
+	tests := []struct {
+		name         string
+		errs         []error
+		wantErr      error
+		wantAttempts int
+	}{
+		{"success", []error{nil}, nil, 1},
+		{"transient then success", []error{serialization, nil}, nil, 2},
+		{"wrapped transient then success", []error{deadlock, deadlock, nil}, nil, 3},
+		{"non-transient fails immediately", []error{uniqueViolation, nil}, uniqueViolation, 1},
+		{"gives up after max attempts", []error{serialization, serialization, serialization, nil}, serialization, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &apiConfig{logger: testLogger, dbMaxAttempts: 3}
+			attempts := 0
+			err := cfg.withDBRetry(context.Background(), func() error {
+				err := tt.errs[attempts]
+				attempts++
+				return err
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestWithDBRetryStopsWhenContextDone(t *testing.T) {
+	cfg := &apiConfig{logger: testLogger, dbMaxAttempts: 10}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	attempts := 0
+	start := time.Now()
+	err := cfg.withDBRetry(ctx, func() error {
+		attempts++
+		return &pq.Error{Code: "40001"}
+	})
+	if err == nil || attempts != 1 {
+		t.Errorf("err = %v after %d attempts, want the transient error after 1", err, attempts)
+	}
+	if elapsed := time.Since(start); elapsed >= dbRetryBackoff {
+		t.Errorf("waited %s despite the canceled context", elapsed)
+	}
+}
+
+func TestCreateChirpRetriesTransientError(t *testing.T) {
+	db, mock := newMockQueries(t)
+	cfg := &apiConfig{db: db, logger: testLogger, jwtSecret: testJWTSecret, dbMaxAttempts: 3}
+	userID := uuid.New()
+	now := time.Now().UTC()
+
+	mock.ExpectQuery("INSERT INTO chirps").WillReturnError(&pq.Error{Code: "40001"})
+	mock.ExpectQuery("INSERT INTO chirps").WillReturnRows(chirpRows(database.Chirp{
+		ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Body: "hello", UserID: userID,
+	}))
+
+	body := `{"body": "hello"}`
+	rec := httptest.NewRecorder()
+	cfg.createChirpHandler(rec, newChirpRequest(t, userID, body))
+	if rec.Code != http.StatusCreated {
+		t.Errorf("status = %d, want 201 (body %s)", rec.Code, rec.Body)
+	}
+}
+
+func TestCreateUserDoesNotRetryPermanentError(t *testing.T) {
+	db, mock := newMockQueries(t)
+	cfg := &apiConfig{db: db, logger: testLogger, dbMaxAttempts: 3}
+
+	mock.ExpectQuery("INSERT INTO users").WillReturnError(&pq.Error{Code: "23502"})
+
+	rec := httptest.NewRecorder()
+	cfg.createUserHandler(rec, httptest.NewRequest(http.MethodPost, "/api/users", strings.NewReader(`{"email": "walt@example.com", "password": "04234"}`)))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500 (body %s)", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/troydot1x/chirpy_server/internal/database"
+)
+
+// Structures for the SEED_FILE format
+type seedFile struct {
+	Users  []seedUser  `json:"users"`
+	Chirps []seedChirp `json:"chirps"`
+}
+
+type seedUser struct {
+	ID        uuid.UUID `json:"id"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type seedChirp struct {
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Body      string     `json:"body"`
+	UserID    uuid.UUID  `json:"user_id"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func readSeedFile(path string) (seedFile, error) {
+	var seed seedFile
+
+	f, err := os.Open(path)
+	if err != nil {
+		return seed, err
+	}
+	defer f.Close()
+
+	decoder := json.NewDecoder(f)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&seed); err != nil {
+		return seed, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	for i, user := range seed.Users {
+		if user.ID == uuid.Nil || user.Email == "" {
+			return seed, fmt.Errorf("user %d: id and email are required", i)
+		}
+		// Stored the same way signup stores them, or login can't find them
+		email, err := normalizeEmail(user.Email)
+		if err != nil {
+			return seed, fmt.Errorf("user %d: %w", i, err)
+		}
+		seed.Users[i].Email = email
+	}
+	for i, chirp := range seed.Chirps {
+		if chirp.ID == uuid.Nil || chirp.UserID == uuid.Nil || chirp.Body == "" {
+			return seed, fmt.Errorf("chirp %d: id, user_id and body are required", i)
+		}
+		if len(chirp.Body) > maxChirpLength {
+			return seed, fmt.Errorf("chirp %d: body is too long", i)
+		}
+	}
+	return seed, nil
+}
+
+// importSeedFile inserts the users and chirps from path in one transaction,
+// skipping any whose ID already exists
+func importSeedFile(ctx context.Context, db *sql.DB, queries *database.Queries, path string) error {
+	seed, err := readSeedFile(path)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := queries.WithTx(tx)
+
+	// Missing timestamps default to the import time
+	now := time.Now().UTC()
+	orNow := func(t time.Time) time.Time {
+		if t.IsZero() {
+			return now
+		}
+		return t.UTC()
+	}
+
+	usersImported := 0
+	for _, user := range seed.Users {
+		n, err := qtx.SeedUser(ctx, database.SeedUserParams{
+			ID:        user.ID,
+			Email:     user.Email,
+			CreatedAt: orNow(user.CreatedAt),
+			UpdatedAt: orNow(user.UpdatedAt),
+		})
+		if err != nil {
+			return fmt.Errorf("importing user %s: %w", user.ID, err)
+		}
+		usersImported += int(n)
+	}
+
+	chirpsImported := 0
+	for _, chirp := range seed.Chirps {
+		var expiresAt sql.NullTime
+		if chirp.ExpiresAt != nil {
+			expiresAt = sql.NullTime{Time: chirp.ExpiresAt.UTC(), Valid: true}
+		}
+		n, err := qtx.SeedChirp(ctx, database.SeedChirpParams{
+			ID:        chirp.ID,
+			CreatedAt: orNow(chirp.CreatedAt),
+			UpdatedAt: orNow(chirp.UpdatedAt),
+			Body:      chirp.Body,
+			UserID:    chirp.UserID,
+			ExpiresAt: expiresAt,
+		})
+		if err != nil {
+			return fmt.Errorf("importing chirp %s: %w", chirp.ID, err)
+		}
+		chirpsImported += int(n)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	slog.Info("imported seed file",
+		"path", path,
+		"users_imported", usersImported,
+		"users_skipped", len(seed.Users)-usersImported,
+		"chirps_imported", chirpsImported,
+		"chirps_skipped", len(seed.Chirps)-chirpsImported,
+	)
+	return nil
+}
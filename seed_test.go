@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/troydot1x/chirpy_server/internal/database"
+)
+
+const seedFixture = `{
+  "users": [
+    {"id": "7d0a6d8f-6f0e-4b5b-9a57-51a1f7d1f0a1", "email": " Walt@Example.com", "created_at": "2024-01-01T00:00:00Z"},
+    {"id": "0c7fbd3c-2f4b-4f0e-8d0c-7d1c5d1a6b12", "email": "jesse@example.com"}
+  ],
+  "chirps": [
+    {"id": "3f1e2d4c-5b6a-4789-8a0b-1c2d3e4f5a6b", "user_id": "7d0a6d8f-6f0e-4b5b-9a57-51a1f7d1f0a1", "body": "Say my name"},
+    {"id": "9a8b7c6d-5e4f-4321-8fed-cba987654321", "user_id": "0c7fbd3c-2f4b-4f0e-8d0c-7d1c5d1a6b12", "body": "Yeah science", "expires_at": "2030-01-01T00:00:00Z"}
+  ]
+}`
+
+func writeSeedFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "seed.json")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("writing seed file: %v", err)
+	}
+	return path
+}
+
+func TestImportSeedFile(t *testing.T) {
+	path := writeSeedFile(t, seedFixture)
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO users").
+		WithArgs("7d0a6d8f-6f0e-4b5b-9a57-51a1f7d1f0a1", "walt@example.com", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// Already present, so ON CONFLICT skips it
+	mock.ExpectExec("INSERT INTO users").
+		WithArgs("0c7fbd3c-2f4b-4f0e-8d0c-7d1c5d1a6b12", "jesse@example.com", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO chirps").
+		WithArgs("3f1e2d4c-5b6a-4789-8a0b-1c2d3e4f5a6b", sqlmock.AnyArg(), sqlmock.AnyArg(), "Say my name", "7d0a6d8f-6f0e-4b5b-9a57-51a1f7d1f0a1", nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO chirps").
+		WithArgs("9a8b7c6d-5e4f-4321-8fed-cba987654321", sqlmock.AnyArg(), sqlmock.AnyArg(), "Yeah science", "0c7fbd3c-2f4b-4f0e-8d0c-7d1c5d1a6b12", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := importSeedFile(context.Background(), db, database.New(db), path); err != nil {
+		t.Fatalf("importSeedFile: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestImportSeedFileRollsBackOnError(t *testing.T) {
+	path := writeSeedFile(t, seedFixture)
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO users").WillReturnError(errors.New("connection reset"))
+	mock.ExpectRollback()
+
+	if err := importSeedFile(context.Background(), db, database.New(db), path); err == nil {
+		t.Fatal("importSeedFile succeeded, want error")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReadSeedFileValidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		wantErr  string
+	}{
+		{"unknown field", `{"users": [], "posts": []}`, "unknown field"},
+		{"user without email", `{"users": [{"id": "7d0a6d8f-6f0e-4b5b-9a57-51a1f7d1f0a1"}]}`, "id and email are required"},
+		{"user with invalid email", `{"users": [{"id": "7d0a6d8f-6f0e-4b5b-9a57-51a1f7d1f0a1", "email": "walt"}]}`, "user 0"},
+		{"chirp without body", `{"chirps": [{"id": "3f1e2d4c-5b6a-4789-8a0b-1c2d3e4f5a6b", "user_id": "7d0a6d8f-6f0e-4b5b-9a57-51a1f7d1f0a1"}]}`, "id, user_id and body are required"},
+		{"chirp too long", `{"chirps": [{"id": "3f1e2d4c-5b6a-4789-8a0b-1c2d3e4f5a6b", "user_id": "7d0a6d8f-6f0e-4b5b-9a57-51a1f7d1f0a1", "body": "` + strings.Repeat("a", maxChirpLength+1) + `"}]}`, "too long"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readSeedFile(writeSeedFile(t, tt.contents))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("readSeedFile error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := readSeedFile(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("readSeedFile of a missing file succeeded")
+	}
+}
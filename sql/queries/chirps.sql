@@ -1,12 +1,130 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
-VALUES ($1, $2, $3, $4, $5)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, expires_at, is_flagged, quoted_chirp_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 RETURNING *;
 
--- name: GetChirps :many
-SELECT * FROM chirps 
-ORDER BY created_at ASC;
+-- name: GetChirpsPaginated :many
+SELECT * FROM chirps
+WHERE (sqlc.narg(user_id)::uuid IS NULL OR user_id = sqlc.narg(user_id))
+AND (sqlc.narg(search)::text IS NULL OR body ILIKE '%' || sqlc.narg(search) || '%')
+AND (expires_at IS NULL OR expires_at > NOW() AT TIME ZONE 'UTC')
+ORDER BY
+    CASE WHEN @sort_desc::bool THEN created_at END DESC,
+    CASE WHEN @sort_desc::bool THEN id END DESC,
+    created_at ASC,
+    id ASC
+LIMIT @max_results OFFSET @skip;
 
 -- name: GetChirpByID :one
 SELECT * FROM chirps 
-WHERE id = $1;
\ No newline at end of file
+WHERE id = $1
+AND (expires_at IS NULL OR expires_at > NOW() AT TIME ZONE 'UTC');
+
+-- name: GetChirpsWithAuthor :many
+SELECT sqlc.embed(chirps),
+    users.email AS author_email,
+    users.created_at AS author_created_at,
+    users.updated_at AS author_updated_at,
+    users.is_chirpy_red AS author_is_chirpy_red
+FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE (sqlc.narg(user_id)::uuid IS NULL OR chirps.user_id = sqlc.narg(user_id))
+AND (sqlc.narg(search)::text IS NULL OR chirps.body ILIKE '%' || sqlc.narg(search) || '%')
+AND (chirps.expires_at IS NULL OR chirps.expires_at > NOW() AT TIME ZONE 'UTC')
+ORDER BY
+    CASE WHEN @sort_desc::bool THEN chirps.created_at END DESC,
+    CASE WHEN @sort_desc::bool THEN chirps.id END DESC,
+    chirps.created_at ASC,
+    chirps.id ASC
+LIMIT @max_results OFFSET @skip;
+
+-- name: CountChirpsByUser :one
+SELECT COUNT(*) FROM chirps
+WHERE user_id = $1;
+
+-- name: GetChirpsSince :many
+SELECT * FROM chirps
+WHERE created_at >= $1
+AND (expires_at IS NULL OR expires_at > NOW() AT TIME ZONE 'UTC')
+ORDER BY created_at DESC;
+
+-- name: GetChirpBodiesByUser :many
+SELECT body FROM chirps
+WHERE user_id = $1
+AND (expires_at IS NULL OR expires_at > NOW() AT TIME ZONE 'UTC')
+ORDER BY created_at DESC
+LIMIT $2;
+
+-- name: DeleteExpiredChirps :execrows
+DELETE FROM chirps
+WHERE expires_at <= NOW() AT TIME ZONE 'UTC';
+
+-- name: SeedChirp :execrows
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (id) DO NOTHING;
+
+-- name: GetChirpsBefore :many
+SELECT * FROM chirps
+WHERE (created_at, id) < (@created_at::timestamp, @id::uuid)
+AND (sqlc.narg(user_id)::uuid IS NULL OR user_id = sqlc.narg(user_id))
+AND (expires_at IS NULL OR expires_at > NOW() AT TIME ZONE 'UTC')
+ORDER BY created_at DESC, id DESC
+LIMIT @max_results;
+
+-- name: GetChirpsAfter :many
+SELECT * FROM chirps
+WHERE (created_at, id) > (@created_at::timestamp, @id::uuid)
+AND (sqlc.narg(user_id)::uuid IS NULL OR user_id = sqlc.narg(user_id))
+AND (expires_at IS NULL OR expires_at > NOW() AT TIME ZONE 'UTC')
+ORDER BY created_at ASC, id ASC
+LIMIT @max_results;
+
+-- name: GetExistingChirpIDs :many
+SELECT id FROM chirps
+WHERE id = ANY(@ids::uuid[])
+AND (expires_at IS NULL OR expires_at > NOW() AT TIME ZONE 'UTC');
+
+-- name: CountChirps :one
+SELECT COUNT(*) FROM chirps
+WHERE (sqlc.narg(user_id)::uuid IS NULL OR user_id = sqlc.narg(user_id))
+AND (sqlc.narg(search)::text IS NULL OR body ILIKE '%' || sqlc.narg(search) || '%')
+AND (expires_at IS NULL OR expires_at > NOW() AT TIME ZONE 'UTC');
+
+-- name: GetChirpIDs :many
+SELECT id, updated_at FROM chirps
+WHERE (sqlc.narg(user_id)::uuid IS NULL OR user_id = sqlc.narg(user_id))
+AND (expires_at IS NULL OR expires_at > NOW() AT TIME ZONE 'UTC')
+ORDER BY
+    CASE WHEN @sort_desc::bool THEN created_at END DESC,
+    CASE WHEN @sort_desc::bool THEN id END DESC,
+    created_at ASC,
+    id ASC;
+
+-- name: DeleteChirp :exec
+DELETE FROM chirps
+WHERE id = $1;
+
+-- name: UpdateChirp :one
+UPDATE chirps
+SET body = $2, is_flagged = $3, updated_at = $4
+WHERE id = $1
+RETURNING *;
+
+-- name: GetChirpsByUserBetween :many
+SELECT * FROM chirps
+WHERE user_id = $1
+AND created_at >= @start_time AND created_at < @end_time
+AND (expires_at IS NULL OR expires_at > NOW() AT TIME ZONE 'UTC')
+ORDER BY created_at ASC;
+
+-- name: GetChirpsByIDs :many
+SELECT * FROM chirps
+WHERE id = ANY(@ids::uuid[])
+AND (expires_at IS NULL OR expires_at > NOW() AT TIME ZONE 'UTC');
+
+-- name: GetQuotesOfChirp :many
+SELECT * FROM chirps
+WHERE quoted_chirp_id = $1
+AND (expires_at IS NULL OR expires_at > NOW() AT TIME ZONE 'UTC')
+ORDER BY created_at ASC;
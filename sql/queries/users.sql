@@ -1,4 +1,34 @@
 -- name: CreateUser :one
+INSERT INTO users (id, email, created_at, updated_at, hashed_password)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetUserByEmail :one
+SELECT * FROM users
+WHERE email = $1;
+
+-- name: GetUserByID :one
+SELECT * FROM users
+WHERE id = $1;
+
+-- name: LockUser :one
+SELECT id FROM users
+WHERE id = $1
+FOR UPDATE;
+
+-- name: SeedUser :execrows
 INSERT INTO users (id, email, created_at, updated_at)
 VALUES ($1, $2, $3, $4)
-RETURNING *;
\ No newline at end of file
+ON CONFLICT (id) DO NOTHING;
+
+-- name: UpdateUser :one
+UPDATE users
+SET email = $2, hashed_password = $3, updated_at = $4
+WHERE id = $1
+RETURNING *;
+
+
+-- name: UpgradeUserToChirpyRed :execrows
+UPDATE users
+SET is_chirpy_red = true, updated_at = $2
+WHERE id = $1;
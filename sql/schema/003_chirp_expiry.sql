@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE chirps ADD COLUMN expires_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE chirps DROP COLUMN expires_at;
\ No newline at end of file
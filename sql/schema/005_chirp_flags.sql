@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE chirps ADD COLUMN is_flagged BOOLEAN NOT NULL DEFAULT false;
+
+-- +goose Down
+ALTER TABLE chirps DROP COLUMN is_flagged;
\ No newline at end of file
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE (email);
+
+-- +goose Down
+ALTER TABLE users DROP CONSTRAINT users_email_key;
\ No newline at end of file
@@ -0,0 +1,24 @@
+-- +goose Up
+-- Accounts whose emails differ only by case would collide on users_email_key
+-- once lowercased; refuse to run until they have been merged by hand
+-- +goose StatementBegin
+DO $$
+DECLARE
+    collisions TEXT;
+BEGIN
+    SELECT string_agg(lowered, ', ') INTO collisions
+    FROM (
+        SELECT LOWER(email) AS lowered FROM users
+        GROUP BY LOWER(email)
+        HAVING COUNT(*) > 1
+    ) duplicates;
+    IF collisions IS NOT NULL THEN
+        RAISE EXCEPTION 'users with emails differing only by case must be merged before lowercasing: %', collisions;
+    END IF;
+END $$;
+-- +goose StatementEnd
+
+UPDATE users SET email = LOWER(email) WHERE email <> LOWER(email);
+
+-- +goose Down
+-- Original casing is not kept, so there is nothing to restore
\ No newline at end of file
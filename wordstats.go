@@ -0,0 +1,66 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Common English words that say nothing about what a user chirps about
+var stopWords = map[string]struct{}{
+	"about": {}, "after": {}, "all": {}, "also": {}, "and": {}, "any": {},
+	"are": {}, "because": {}, "been": {}, "but": {}, "can": {}, "could": {},
+	"did": {}, "does": {}, "for": {}, "from": {}, "get": {}, "got": {},
+	"had": {}, "has": {}, "have": {}, "her": {}, "here": {}, "him": {},
+	"his": {}, "how": {}, "its": {}, "just": {}, "like": {}, "more": {},
+	"not": {}, "now": {}, "one": {}, "our": {}, "out": {}, "she": {},
+	"some": {}, "than": {}, "that": {}, "the": {}, "their": {}, "them": {},
+	"then": {}, "there": {}, "these": {}, "they": {}, "this": {}, "too": {},
+	"very": {}, "was": {}, "were": {}, "what": {}, "when": {}, "where": {},
+	"which": {}, "who": {}, "why": {}, "will": {}, "with": {}, "would": {},
+	"you": {}, "your": {},
+}
+
+type WordCount struct {
+	Word  string `json:"word"`
+	Count int    `json:"count"`
+}
+
+// topWords counts the words in bodies, skipping stop-words and anything
+// shorter than three letters, and returns the n most frequent
+func topWords(bodies []string, n int) []WordCount {
+	counts := make(map[string]int)
+	for _, body := range bodies {
+		words := strings.FieldsFunc(strings.ToLower(body), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+		})
+		for _, word := range words {
+			word = strings.Trim(word, "'")
+			if len([]rune(word)) < 3 {
+				continue
+			}
+			if _, ok := stopWords[word]; ok {
+				continue
+			}
+			counts[word]++
+		}
+	}
+
+	result := make([]WordCount, 0, len(counts))
+	for word, count := range counts {
+		result = append(result, WordCount{Word: word, Count: count})
+	}
+
+	// Most frequent first, alphabetical among ties so output is stable
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Word < result[j].Word
+	})
+
+	if len(result) > n {
+		result = result[:n]
+	}
+	return result
+}
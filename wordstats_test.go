@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/troydot1x/chirpy_server/internal/database"
+)
+
+var wordStatsBodies = []string{
+	"Gophers love channels. Channels love gophers!",
+	"I can't stop writing Go; channels are great",
+	"The gopher and the channel",
+	"a an of to",
+}
+
+func TestTopWords(t *testing.T) {
+	tests := []struct {
+		name string
+		n    int
+		want []WordCount
+	}{
+		{
+			name: "counts and orders words",
+			n:    4,
+			want: []WordCount{
+				{Word: "channels", Count: 3},
+				{Word: "gophers", Count: 2},
+				{Word: "love", Count: 2},
+				{Word: "can't", Count: 1},
+			},
+		},
+		{
+			name: "caps the result",
+			n:    1,
+			want: []WordCount{{Word: "channels", Count: 3}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := topWords(wordStatsBodies, tt.n); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("topWords = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if got := topWords([]string{"the and of it"}, 10); len(got) != 0 {
+		t.Errorf("stop-words and short words were counted: %+v", got)
+	}
+}
+
+func TestGetUserWordStatsHandler(t *testing.T) {
+	userID := uuid.New()
+
+	t.Run("seeded chirps", func(t *testing.T) {
+		db, mock := newMockQueries(t)
+		cfg := &apiConfig{db: db, logger: testLogger}
+		mock.ExpectQuery("FROM users").WithArgs(userID).WillReturnRows(userRows(database.User{ID: userID, Email: "walt@example.com"}))
+		rows := sqlmock.NewRows([]string{"body"})
+		for _, body := range wordStatsBodies {
+			rows.AddRow(body)
+		}
+		mock.ExpectQuery("SELECT body FROM chirps").WithArgs(userID, maxWordStatsChirps).WillReturnRows(rows)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/users/"+userID.String()+"/word-stats?limit=2", nil)
+		req.SetPathValue("userID", userID.String())
+		rec := httptest.NewRecorder()
+		cfg.getUserWordStatsHandler(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200 (body %s)", rec.Code, rec.Body)
+		}
+		var got []WordCount
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+		want := []WordCount{{Word: "channels", Count: 3}, {Word: "gophers", Count: 2}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		db, mock := newMockQueries(t)
+		cfg := &apiConfig{db: db, logger: testLogger}
+		mock.ExpectQuery("FROM users").WithArgs(userID).WillReturnRows(userRows())
+
+		req := httptest.NewRequest(http.MethodGet, "/api/users/"+userID.String()+"/word-stats", nil)
+		req.SetPathValue("userID", userID.String())
+		rec := httptest.NewRecorder()
+		cfg.getUserWordStatsHandler(rec, req)
+
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want 404", rec.Code)
+		}
+	})
+
+	t.Run("limit out of range", func(t *testing.T) {
+		cfg := &apiConfig{logger: testLogger}
+		req := httptest.NewRequest(http.MethodGet, "/api/users/"+userID.String()+"/word-stats?limit=51", nil)
+		req.SetPathValue("userID", userID.String())
+		rec := httptest.NewRecorder()
+		cfg.getUserWordStatsHandler(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want 400", rec.Code)
+		}
+	})
+}